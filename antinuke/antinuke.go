@@ -5,6 +5,11 @@ import (
     "fmt"
     "database/sql"
     "strconv"
+
+    "aware/events"
+    "aware/logging"
+    "aware/router"
+    "aware/schema"
 )
 
 var (
@@ -24,10 +29,15 @@ var (
     quarantineButton = "quarantine_antinuke" 
 )
 
+// Config is a guild's antinuke configuration.
 type Config struct {
-    ActionsPerMinute int
-    ActionsPerHour   int
-    PunishmentType   string
+    ActionsPerMinute int    `json:"actions_per_minute"`
+    ActionsPerHour   int    `json:"actions_per_hour"`
+    PunishmentType   string `json:"punishment_type"`
+    Mode             string `json:"mode"`
+    LogsChannelID    string `json:"logs_channel_id"`
+    ModLogsChannelID string `json:"mod_logs_channel_id"`
+    QuarantineRoleID string `json:"quarantine_role_id"`
 }
 
 func stringPtr(s string) *string {
@@ -38,6 +48,29 @@ func stringPtr(s string) *string {
 func InitAntinuke(database *sql.DB) {
     db = database
     createTables()
+
+    logging.SetDefault(logging.CategoryAntinuke, setupLogDestination(false))
+    logging.SetDefault(logging.CategoryModeration, setupLogDestination(true))
+}
+
+// Limits for guilds that haven't configured their own, matching the
+// antinuke_config column defaults.
+const (
+    defaultActionsPerMinute = 5
+    defaultActionsPerHour   = 20
+)
+
+// getLimits returns the guild's action limits, or the defaults.
+func getLimits(guildID string) (int, int) {
+    var apm, aph int
+    err := db.QueryRow(`
+        SELECT actions_per_minute, actions_per_hour
+        FROM antinuke_config
+        WHERE guild_id = ?`, guildID).Scan(&apm, &aph)
+    if err != nil {
+        return defaultActionsPerMinute, defaultActionsPerHour
+    }
+    return apm, aph
 }
 
 func insertInitialConfig(guildID string) error {
@@ -48,8 +81,8 @@ func insertInitialConfig(guildID string) error {
             actions_per_hour,
             punishment_type,
             enabled
-        ) VALUES (?, 5, 20, 'quarantine', true)`,
-        guildID,
+        ) VALUES (?, ?, ?, 'quarantine', true)`,
+        guildID, defaultActionsPerMinute, defaultActionsPerHour,
     )
     return err
 }
@@ -71,8 +104,8 @@ func ensureGuildConfig(guildID string) error {
                 actions_per_hour,
                 punishment_type,
                 enabled
-            ) VALUES (?, 5, 20, 'quarantine', true)`,
-            guildID,
+            ) VALUES (?, ?, ?, 'quarantine', true)`,
+            guildID, defaultActionsPerMinute, defaultActionsPerHour,
         )
     }
     return err
@@ -92,36 +125,44 @@ func createTables() {
             quarantine_role_id TEXT,
             webhook_id TEXT,
             mod_webhook_id TEXT,
-            enabled BOOLEAN DEFAULT true
+            enabled BOOLEAN DEFAULT true,
+            monitor_mode BOOLEAN DEFAULT false
         )
     `)
     if err != nil {
         fmt.Printf("Error creating tables: %v\n", err)
     }
-}
 
+    // Columns added after the original schema
+    for _, column := range [][3]string{
+        {"antinuke_config", "monitor_mode", "BOOLEAN DEFAULT false"},
+        {"antinuke_whitelist", "expires_at", "TIMESTAMP"},
+    } {
+        if err := schema.AddColumnIfMissing(db, column[0], column[1], column[2]); err != nil {
+            fmt.Printf("Error migrating schema: %v\n", err)
+        }
+    }
+
+    createIncidentTables()
+}
 
 func Int64Ptr(i int64) *int64 {
     return &i
 }
 
-func SetupCommand(s *discordgo.Session, m *discordgo.MessageCreate) {
+func setupCommand(ctx *router.Context) error {
+    embed, components := setupPanel()
 
-    
-    if m.Content != ",antinuke setup" {
-        return
-    }
-
-    guild, err := s.Guild(m.GuildID)
-    if err != nil {
-        return
-    }
-
-    if m.Author.ID != guild.OwnerID {
-        s.ChannelMessageSend(m.ChannelID, "Only the server owner can use this command!")
-        return
-    }
+    msg, err := ctx.Session.ChannelMessageSendComplex(ctx.ChannelID, &discordgo.MessageSend{
+        Embeds:     []*discordgo.MessageEmbed{embed},
+        Components: router.BindComponents(components, ctx.UserID, ctx.GuildID),
+    })
+    router.ExpireMessage(ctx.Session, msg)
+    return err
+}
 
+// setupPanel builds the setup embed and its buttons.
+func setupPanel() (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
     embed := &discordgo.MessageEmbed{
         Title:       "Anti-Nuke Setup",
         Description: "Configure the Anti-Nuke protection system for your server",
@@ -139,6 +180,10 @@ func SetupCommand(s *discordgo.Session, m *discordgo.MessageCreate) {
                 Name:  "Config",
                 Value: "Displays current Anti-Nuke settings",
             },
+            {
+                Name:  "Mode",
+                Value: "Enable, monitor only (log without punishing) or disable detections",
+            },
         },
     }
 
@@ -174,16 +219,28 @@ func SetupCommand(s *discordgo.Session, m *discordgo.MessageCreate) {
                 },
             },
         },
+        discordgo.ActionsRow{
+            Components: []discordgo.MessageComponent{
+                discordgo.Button{
+                    Label:    "Enable",
+                    Style:    discordgo.SuccessButton,
+                    CustomID: enableModeButton,
+                },
+                discordgo.Button{
+                    Label:    "Monitor Only",
+                    Style:    discordgo.PrimaryButton,
+                    CustomID: monitorModeButton,
+                },
+                discordgo.Button{
+                    Label:    "Disable",
+                    Style:    discordgo.DangerButton,
+                    CustomID: disableModeButton,
+                },
+            },
+        },
     }    
 
-    _, err = s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
-        Embeds:     []*discordgo.MessageEmbed{embed},
-        Components: components,
-    })
-
-    if err != nil {
-        fmt.Printf("Error sending setup message: %v\n", err)
-    }
+    return embed, components
 }
 
 func handlePunishmentOptions(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -220,13 +277,141 @@ func handlePunishmentOptions(s *discordgo.Session, i *discordgo.InteractionCreat
         Type: discordgo.InteractionResponseChannelMessageWithSource,
         Data: &discordgo.InteractionResponseData{
             Embeds:     []*discordgo.MessageEmbed{punishEmbed},
-            Components: punishmentComponents,
+            Components: router.BindComponents(punishmentComponents, i.Member.User.ID, i.GuildID),
             Flags:      discordgo.MessageFlagsEphemeral,
         },
     })
 }
 
 
+// setupRollback tracks resources created during a setup run so they can be
+// removed again if a later step fails.
+type setupRollback struct {
+    guildID  string
+    channels []string
+    webhooks []string
+    roles    []string
+}
+
+func (r *setupRollback) run(s *discordgo.Session) {
+    for _, id := range r.webhooks {
+        s.WebhookDelete(id)
+    }
+    for _, id := range r.channels {
+        s.ChannelDelete(id)
+    }
+    for _, id := range r.roles {
+        s.GuildRoleDelete(r.guildID, id)
+    }
+}
+
+// ensureSetupChannel returns the configured log channel if it still exists,
+// otherwise an existing channel with the same name, hidden from @everyone
+// like a new one, otherwise a new one.
+func ensureSetupChannel(s *discordgo.Session, channels []*discordgo.Channel, configuredID, name string, rb *setupRollback) (*discordgo.Channel, bool, error) {
+    for _, channel := range channels {
+        if configuredID != "" && channel.ID == configuredID {
+            return channel, false, nil
+        }
+    }
+    for _, channel := range channels {
+        if channel.Type == discordgo.ChannelTypeGuildText && channel.Name == name {
+            if err := hideFromEveryone(s, rb.guildID, channel); err != nil {
+                return nil, false, err
+            }
+            return channel, false, nil
+        }
+    }
+
+    channel, err := createLogChannel(s, rb.guildID, name)
+    if err != nil {
+        return nil, false, err
+    }
+    rb.channels = append(rb.channels, channel.ID)
+    return channel, true, nil
+}
+
+// ensureSetupWebhook reuses the configured webhook when it is alive and still
+// points at channelID, otherwise creates a new one.
+func ensureSetupWebhook(s *discordgo.Session, channelID, configuredURL, name string, rb *setupRollback) (string, error) {
+    if id, token, ok := parseWebhookURL(configuredURL); ok {
+        if webhook, err := s.WebhookWithToken(id, token); err == nil && webhook.ChannelID == channelID {
+            return configuredURL, nil
+        }
+    }
+
+    webhook, err := s.WebhookCreate(channelID, name, "")
+    if err != nil {
+        return "", err
+    }
+    rb.webhooks = append(rb.webhooks, webhook.ID)
+    return webhookURL(webhook), nil
+}
+
+func ensureSetupRole(s *discordgo.Session, roles []*discordgo.Role, configuredID string, rb *setupRollback) (*discordgo.Role, bool, error) {
+    for _, role := range roles {
+        if configuredID != "" && role.ID == configuredID {
+            return role, false, nil
+        }
+    }
+    for _, role := range roles {
+        if role.Name == quarantineRole && !role.Managed {
+            return role, false, nil
+        }
+    }
+
+    role, err := createQuarantineRole(s, rb.guildID)
+    if err != nil {
+        return nil, false, err
+    }
+    rb.roles = append(rb.roles, role.ID)
+    return role, true, nil
+}
+
+// saveSetupConfig writes all setup resources in a single transaction.
+// Limits, punishment type and enabled state of an existing row are kept.
+func saveSetupConfig(guildID, logsID, modLogsID, roleID, webhook, modWebhook string) error {
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    _, err = tx.Exec(`
+        INSERT OR IGNORE INTO antinuke_config (
+            guild_id,
+            actions_per_minute,
+            actions_per_hour,
+            punishment_type,
+            enabled
+        ) VALUES (?, ?, ?, 'quarantine', true)`,
+        guildID, defaultActionsPerMinute, defaultActionsPerHour,
+    )
+    if err != nil {
+        return err
+    }
+
+    _, err = tx.Exec(`
+        UPDATE antinuke_config
+        SET logs_channel_id = ?, mod_logs_channel_id = ?, quarantine_role_id = ?,
+            webhook_id = ?, mod_webhook_id = ?
+        WHERE guild_id = ?`,
+        logsID, modLogsID, roleID, webhook, modWebhook, guildID,
+    )
+    if err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+func describeSetupResource(mention string, created bool) string {
+    if created {
+        return fmt.Sprintf("• %s (created)", mention)
+    }
+    return fmt.Sprintf("• %s (reused)", mention)
+}
+
 func handleStartSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
     s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
         Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
@@ -234,139 +419,91 @@ func handleStartSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
     guildID := i.GuildID
 
-    
+    // Start from whatever a previous setup run left behind
+    existing, err := getGuildResources(guildID)
+    if err != nil {
+        existing = &guildResources{}
+    }
 
-    // Create Anti-Nuke Logs channel
-    antiNukeLogs, err := s.GuildChannelCreate(guildID, "antinuke-logs", discordgo.ChannelTypeGuildText)
+    channels, err := s.GuildChannels(guildID)
     if err != nil {
-        respondWithError(s, i, "Failed to create antinuke-logs channel")
+        respondWithError(s, i, "Failed to get guild channels")
         return
     }
 
-    // Hide channel from @everyone
-    err = s.ChannelPermissionSet(
-        antiNukeLogs.ID, 
-        guildID, // Changed from i.Guild.ID to guildID
-        discordgo.PermissionOverwriteTypeRole,
-        0, 
-        discordgo.PermissionViewChannel,
-    )
+    roles, err := s.GuildRoles(guildID)
     if err != nil {
-        respondWithError(s, i, "Failed to set channel permissions")
+        respondWithError(s, i, "Failed to get guild roles")
         return
     }
 
+    rb := &setupRollback{guildID: guildID}
+
+    // Serialise with self-defense repairs while resources are in flux
+    repairMutex.Lock()
+    defer repairMutex.Unlock()
 
-    // Create Mod Logs channel with hidden permissions
-    modLogs, err := s.GuildChannelCreate(guildID, "mod-logs", discordgo.ChannelTypeGuildText)
+    antiNukeLogs, logsCreated, err := ensureSetupChannel(s, channels, existing.LogsChannelID, "antinuke-logs", rb)
     if err != nil {
-        respondWithError(s, i, "Failed to create mod-logs channel")
+        rb.run(s)
+        respondWithError(s, i, "Failed to create antinuke-logs channel")
         return
     }
 
-    // Hide channel from @everyone
-    err = s.ChannelPermissionSet(
-        modLogs.ID, 
-        guildID, // Changed from i.Guild.ID to guildID
-        discordgo.PermissionOverwriteTypeRole,
-        0, 
-        discordgo.PermissionViewChannel,
-    )
-        if err != nil {
-        respondWithError(s, i, "Failed to set channel permissions")
+    modLogs, modLogsCreated, err := ensureSetupChannel(s, channels, existing.ModLogsChannelID, "mod-logs", rb)
+    if err != nil {
+        rb.run(s)
+        respondWithError(s, i, "Failed to create mod-logs channel")
         return
     }
 
-    
-
-    // Create webhook for Anti-Nuke Logs
-    antiNukeWebhook, err := s.WebhookCreate(antiNukeLogs.ID, "Anti-Nuke Logs", "")
+    antiNukeWebhookURL, err := ensureSetupWebhook(s, antiNukeLogs.ID, existing.WebhookURL, "Anti-Nuke Logs", rb)
     if err != nil {
+        rb.run(s)
         respondWithError(s, i, "Failed to create antinuke webhook")
         return
     }
 
-    // Create webhook for Mod Logs
-    modWebhook, err := s.WebhookCreate(modLogs.ID, "Mod Logs", "")
+    modWebhookURL, err := ensureSetupWebhook(s, modLogs.ID, existing.ModWebhookURL, "Mod Logs", rb)
     if err != nil {
+        rb.run(s)
         respondWithError(s, i, "Failed to create mod webhook")
         return
     }
 
-// Create Quarantined role
-    var permissions int64 = 0
-    color := 0x36393f
-    roleParams := &discordgo.RoleParams{
-        Name:        quarantineRole,
-        Permissions: &permissions,
-        Color:       &color,
-    }
-
-    
-    quarantinedRole, err := s.GuildRoleCreate(guildID, roleParams)
+    quarantinedRole, roleCreated, err := ensureSetupRole(s, roles, existing.QuarantineRoleID, rb)
     if err != nil {
+        rb.run(s)
         respondWithError(s, i, "Failed to create quarantined role")
         return
     }
 
-    // Get all existing channels
-    channels, err := s.GuildChannels(guildID)
-    if err != nil {
-        respondWithError(s, i, "Failed to get guild channels")
-        return
+    // Disable view permissions for quarantine role in all existing channels,
+    // including the log channels created above
+    targets := channels
+    if logsCreated {
+        targets = append(targets, antiNukeLogs)
     }
-
-    // Disable view permissions for quarantine role in all existing channels
-    for _, channel := range channels {
-        err = s.ChannelPermissionSet(
-            channel.ID,
-            quarantinedRole.ID,
-            discordgo.PermissionOverwriteTypeRole,
-            0,
-            discordgo.PermissionViewChannel,
-        )
+    if modLogsCreated {
+        targets = append(targets, modLogs)
+    }
+    for _, channel := range targets {
+        if hasQuarantineOverwrite(channel, quarantinedRole.ID) {
+            continue
+        }
+        err = denyQuarantineView(s, channel.ID, quarantinedRole.ID)
         if err != nil {
             fmt.Printf("Error setting permissions for channel %s: %v\n", channel.ID, err)
             continue
         }
     }
 
-    // Create event handler for new channels
-    s.AddHandler(func(s *discordgo.Session, c *discordgo.ChannelCreate) {
-        if c.GuildID == guildID {
-            err := s.ChannelPermissionSet(
-                c.ID,
-                quarantinedRole.ID,
-                discordgo.PermissionOverwriteTypeRole,
-                0,
-                discordgo.PermissionViewChannel,
-            )
-            if err != nil {
-                fmt.Printf("Error setting permissions for new channel %s: %v\n", c.ID, err)
-            }
-        }
-    })
-
-    // Store role ID in database for future reference
-    _, err = db.Exec(`
-        UPDATE antinuke_config 
-        SET quarantine_role_id = ?
-        WHERE guild_id = ?`,
-        quarantinedRole.ID, guildID,
-    )
-    if err != nil {
-        fmt.Printf("Error storing quarantine role in database: %v\n", err)
-    }
-
-    // Store configuration in database
-    _, err = db.Exec(`
-        INSERT OR REPLACE INTO antinuke_config 
-        (guild_id, logs_channel_id, mod_logs_channel_id, quarantine_role_id, webhook_id, mod_webhook_id, enabled) 
-        VALUES (?, ?, ?, ?, ?, ?, true)`,
-        guildID, antiNukeLogs.ID, modLogs.ID, quarantinedRole.ID, antiNukeWebhook.ID, modWebhook.ID,
-    )
+    err = saveSetupConfig(guildID, antiNukeLogs.ID, modLogs.ID, quarantinedRole.ID, antiNukeWebhookURL, modWebhookURL)
     if err != nil {
         fmt.Printf("Error storing config in database: %v\n", err)
+        rb.run(s)
+        respondWithError(s, i, "Failed to save configuration")
+        return
     }
 
     successEmbed := &discordgo.MessageEmbed{
@@ -375,12 +512,13 @@ func handleStartSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
         Color:       0x00ff00,
         Fields: []*discordgo.MessageEmbedField{
             {
-                Name:  "Channels Created",
-                Value: fmt.Sprintf("• %s\n• %s", antiNukeLogs.Mention(), modLogs.Mention()),
+                Name: "Channels",
+                Value: describeSetupResource(antiNukeLogs.Mention(), logsCreated) + "\n" +
+                    describeSetupResource(modLogs.Mention(), modLogsCreated),
             },
             {
-                Name:  "Role Created",
-                Value: fmt.Sprintf("• %s", quarantinedRole.Name),
+                Name:  "Role",
+                Value: describeSetupResource(quarantinedRole.Name, roleCreated),
             },
         },
     }
@@ -415,35 +553,15 @@ func handleStartSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
         Color:       0xff0000,
     }
 
-    s.ChannelMessageSendComplex(i.ChannelID, &discordgo.MessageSend{
+    msg, _ := s.ChannelMessageSendComplex(i.ChannelID, &discordgo.MessageSend{
         Embeds:     []*discordgo.MessageEmbed{punishEmbed},
-        Components: punishmentComponents,
-    })    
+        Components: router.BindComponents(punishmentComponents, i.Member.User.ID, i.GuildID),
+    })
+    router.ExpireMessage(s, msg)
 
     s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
         Embeds: &[]*discordgo.MessageEmbed{successEmbed},
     })
-
-    // Store complete webhook URLs
-    webhookURL := fmt.Sprintf("https://discord.com/api/webhooks/%s/%s", antiNukeWebhook.ID, antiNukeWebhook.Token)
-    modWebhookURL := fmt.Sprintf("https://discord.com/api/webhooks/%s/%s", modWebhook.ID, modWebhook.Token)
-    
-    // Store complete webhook URLs
-    result, err := db.Exec(`
-        INSERT OR REPLACE INTO antinuke_config 
-        (guild_id, logs_channel_id, mod_logs_channel_id, quarantine_role_id, webhook_id, mod_webhook_id, enabled) 
-        VALUES (?, ?, ?, ?, ?, ?, true)`,
-        guildID, antiNukeLogs.ID, modLogs.ID, quarantinedRole.ID, webhookURL, modWebhookURL,
-        
-    )
-
-    _ = result
-    
-    if err != nil {
-        fmt.Printf("Error storing webhook URLs in database: %v\n", err)
-        return
-    }
-    
 }
 
 func handleDeleteSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -460,7 +578,16 @@ func handleDeleteSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
         FROM antinuke_config 
         WHERE guild_id = ?`, guildID).Scan(&logsChannelID, &modLogsChannelID, &quarantineRoleID)
     
-    if err == nil {
+    found := err == nil
+
+    // Remove from database first so self-defense doesn't recreate the
+    // resources deleted below
+    _, err = db.Exec(`DELETE FROM antinuke_config WHERE guild_id = ?`, guildID)
+    if err != nil {
+        fmt.Printf("Error removing config from database: %v\n", err)
+    }
+
+    if found {
         // Delete channels
         if logsChannelID != "" {
             s.ChannelDelete(logsChannelID)
@@ -473,12 +600,6 @@ func handleDeleteSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
         }
     }
 
-    // Remove from database
-    _, err = db.Exec(`DELETE FROM antinuke_config WHERE guild_id = ?`, guildID)
-    if err != nil {
-        fmt.Printf("Error removing config from database: %v\n", err)
-    }
-
     deleteEmbed := &discordgo.MessageEmbed{
         Title:       "Setup Deleted",
         Description: "All Anti-Nuke configurations have been removed",
@@ -493,24 +614,12 @@ func handleDeleteSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
 
 func disableButton(s *discordgo.Session, i *discordgo.InteractionCreate, buttonID string) {
-    for _, row := range i.Message.Components {
-        if actionRow, ok := row.(discordgo.ActionsRow); ok {
-            for _, comp := range actionRow.Components {
-                if button, ok := comp.(discordgo.Button); ok {
-                    if button.CustomID == buttonID {
-                        button.Disabled = true
-                        components := i.Message.Components
-                        s.ChannelMessageEditComplex(&discordgo.MessageEdit{
-                            Channel:    i.ChannelID,
-                            ID:        i.Message.ID,
-                            Components: &components,
-                        })
-                        return
-                    }
-                }
-            }
-        }
-    }
+    components := router.DisableComponent(i.Message.Components, buttonID)
+    s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+        Channel:    i.ChannelID,
+        ID:        i.Message.ID,
+        Components: &components,
+    })
 }
 
 
@@ -530,8 +639,15 @@ func handleViewConfig(s *discordgo.Session, i *discordgo.InteractionCreate) {
         WHERE guild_id = ?`, guildID).Scan(&logsChannelID, &modLogsChannelID, &quarantineRoleID, &enabled)
 
     status := "Not Configured"
-    if err == nil && enabled {
-        status = "Active"
+    if err == nil {
+        switch getProtectionMode(guildID) {
+        case modeActive:
+            status = "Active"
+        case modeMonitor:
+            status = "Monitor Only"
+        case modeDisabled:
+            status = "Disabled"
+        }
     }
 
     configEmbed := &discordgo.MessageEmbed{
@@ -555,26 +671,9 @@ func handleViewConfig(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 
-func HandleSetupButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
-    if i.Type != discordgo.InteractionMessageComponent {
-        return
-    }
-
-    switch i.MessageComponentData().CustomID {
-    case setupButton:
-        handleStartSetup(s, i)
-        disableButton(s, i, setupButton)
-    case deleteButton:
-        handleDeleteSetup(s, i)
-    case configButton:
-        handleViewConfig(s, i)
-    case punishButton:
-        handlePunishmentOptions(s, i)
-    case kickButton, banButton, quarantineButton:
-        handlePunishmentSetup(s, i)
-    case limitsButton:
-        handleLimitsSetup(s, i)
-    }
+func handleStartSetupButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+    handleStartSetup(s, i)
+    disableButton(s, i, setupButton)
 }
 
 
@@ -629,6 +728,9 @@ func setPunishment(s *discordgo.Session, i *discordgo.InteractionCreate, punishT
         return
     }
 
+    logging.LogConfigChange(s, i.GuildID, i.Member.User.ID, "Anti-Nuke punishment", punishType)
+    events.PublishConfigUpdate(i.GuildID, i.Member.User.ID, "Anti-Nuke punishment", punishType)
+
     successEmbed := &discordgo.MessageEmbed{
         Title:       "Punishment Updated",
         Description: fmt.Sprintf("Punishment type set to: %s", punishType),
@@ -644,7 +746,7 @@ func handleLimitsSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
     modal := &discordgo.InteractionResponse{
         Type: discordgo.InteractionResponseModal,
         Data: &discordgo.InteractionResponseData{
-            CustomID: "limits_modal",
+            CustomID: router.Bind("limits_modal", i.Member.User.ID, i.GuildID, router.ComponentTTL),
             Title:    "Set Action Limits",
             Components: []discordgo.MessageComponent{
                 discordgo.ActionsRow{
@@ -680,7 +782,7 @@ func handleLimitsSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
     s.InteractionRespond(i.Interaction, modal)
 }
 
-func HandleLimitsModal(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func handleLimitsModal(s *discordgo.Session, i *discordgo.InteractionCreate) {
     data := i.ModalSubmitData()
     
     // Parse actions per minute
@@ -722,23 +824,18 @@ func HandleLimitsModal(s *discordgo.Session, i *discordgo.InteractionCreate) {
     }
 
     // Validate the numbers
-    if apm < 1 || aph < 1 {
+    if err := ValidateLimits(apm, aph); err != nil {
         s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
             Type: discordgo.InteractionResponseChannelMessageWithSource,
             Data: &discordgo.InteractionResponseData{
-                Content: "Values must be greater than 0",
+                Content: err.Error(),
                 Flags:   discordgo.MessageFlagsEphemeral,
             },
         })
         return
     }
 
-    _, err = db.Exec(`
-        UPDATE antinuke_config 
-        SET actions_per_minute = ?, actions_per_hour = ?
-        WHERE guild_id = ?`,
-        apm, aph, i.GuildID,
-    )
+    err = updateLimits(i.GuildID, apm, aph)
 
     if err != nil {
         s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -751,6 +848,9 @@ func HandleLimitsModal(s *discordgo.Session, i *discordgo.InteractionCreate) {
         return
     }
 
+    logging.LogConfigChange(s, i.GuildID, i.Member.User.ID, "Anti-Nuke limits", fmt.Sprintf("%d/minute, %d/hour", apm, aph))
+    events.PublishConfigUpdate(i.GuildID, i.Member.User.ID, "Anti-Nuke limits", fmt.Sprintf("%d/minute, %d/hour", apm, aph))
+
     successEmbed := &discordgo.MessageEmbed{
         Title: "Limits Updated",
         Description: fmt.Sprintf("Actions per minute: %d\nActions per hour: %d", apm, aph),
@@ -766,3 +866,27 @@ func HandleLimitsModal(s *discordgo.Session, i *discordgo.InteractionCreate) {
     })
 }
 
+// ValidateLimits applies the same bounds as the limits modal inputs. The
+// slash command and dashboard use it too.
+func ValidateLimits(apm, aph int) error {
+    if apm < 1 || aph < 1 {
+        return fmt.Errorf("Values must be greater than 0")
+    }
+    if apm > 99 {
+        return fmt.Errorf("Actions per minute must be at most 99")
+    }
+    if aph > 999 {
+        return fmt.Errorf("Actions per hour must be at most 999")
+    }
+    return nil
+}
+
+func updateLimits(guildID string, apm, aph int) error {
+    _, err := db.Exec(`
+        UPDATE antinuke_config 
+        SET actions_per_minute = ?, actions_per_hour = ?
+        WHERE guild_id = ?`,
+        apm, aph, guildID,
+    )
+    return err
+}
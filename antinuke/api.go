@@ -0,0 +1,347 @@
+package antinuke
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"aware/events"
+	"aware/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// PunishmentTypes lists the valid punishment_type values.
+var PunishmentTypes = []string{"quarantine", "kick", "ban"}
+
+// Modes lists the valid protection modes.
+var Modes = []string{modeActive, modeMonitor, modeDisabled}
+
+// ValidationError is returned for changes rejected before touching the
+// database.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// GetConfig returns a guild's configuration, with defaults for guilds that
+// haven't run setup.
+func GetConfig(guildID string) (Config, error) {
+	config := Config{ActionsPerMinute: defaultActionsPerMinute, ActionsPerHour: defaultActionsPerHour, PunishmentType: "quarantine"}
+
+	var logs, modLogs, role, punishment sql.NullString
+	var apm, aph sql.NullInt64
+	err := db.QueryRow(`
+        SELECT actions_per_minute, actions_per_hour, punishment_type,
+               logs_channel_id, mod_logs_channel_id, quarantine_role_id
+        FROM antinuke_config WHERE guild_id = ?`, guildID).Scan(&apm, &aph, &punishment, &logs, &modLogs, &role)
+	if err != nil && err != sql.ErrNoRows {
+		return config, err
+	}
+
+	if apm.Valid {
+		config.ActionsPerMinute = int(apm.Int64)
+	}
+	if aph.Valid {
+		config.ActionsPerHour = int(aph.Int64)
+	}
+	if punishment.String != "" {
+		config.PunishmentType = punishment.String
+	}
+	config.LogsChannelID, config.ModLogsChannelID = logs.String, modLogs.String
+	config.QuarantineRoleID = role.String
+	config.Mode = getProtectionMode(guildID)
+	return config, nil
+}
+
+// logChange records a configuration change made outside Discord to the
+// guild's antinuke logs.
+func logChange(s *discordgo.Session, guildID, userID, setting, value string) {
+	embed := &discordgo.MessageEmbed{
+		Title:       "Anti-Nuke Config Changed",
+		Description: fmt.Sprintf("**User:** <@%s>\n**Setting:** %s\n**Value:** %s", userID, setting, value),
+		Color:       0x3498db,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Changed from the dashboard",
+		},
+	}
+
+	if err := logging.Send(s, guildID, logging.CategoryAntinuke, embed); err != nil {
+		fmt.Printf("Failed to send config change log: %v\n", err)
+	}
+	events.PublishConfigUpdate(guildID, userID, setting, value)
+}
+
+// SetLimits validates and stores the action limits on behalf of userID.
+func SetLimits(s *discordgo.Session, guildID, userID string, apm, aph int) error {
+	if err := ValidateLimits(apm, aph); err != nil {
+		return &ValidationError{err.Error()}
+	}
+	if err := ensureGuildConfig(guildID); err != nil {
+		return err
+	}
+	if err := updateLimits(guildID, apm, aph); err != nil {
+		return err
+	}
+
+	logChange(s, guildID, userID, "Limits", fmt.Sprintf("%d/minute, %d/hour", apm, aph))
+	return nil
+}
+
+// ValidatePunishment checks a punishment type without storing it.
+func ValidatePunishment(punishType string) error {
+	if punishType != "quarantine" && punishType != "kick" && punishType != "ban" {
+		return &ValidationError{"Punishment must be quarantine, kick or ban"}
+	}
+	return nil
+}
+
+// SetPunishment stores the punishment type on behalf of userID.
+func SetPunishment(s *discordgo.Session, guildID, userID, punishType string) error {
+	if err := ValidatePunishment(punishType); err != nil {
+		return err
+	}
+
+	if err := ensureGuildConfig(guildID); err != nil {
+		return err
+	}
+	if _, err := db.Exec("UPDATE antinuke_config SET punishment_type = ? WHERE guild_id = ?", punishType, guildID); err != nil {
+		return err
+	}
+
+	logChange(s, guildID, userID, "Punishment", punishType)
+	return nil
+}
+
+// ValidateMode checks a protection mode without storing it.
+func ValidateMode(mode string) error {
+	if mode != modeActive && mode != modeMonitor && mode != modeDisabled {
+		return &ValidationError{"Mode must be active, monitor or disabled"}
+	}
+	return nil
+}
+
+// SetMode stores the protection mode on behalf of userID.
+func SetMode(s *discordgo.Session, guildID, userID, mode string) error {
+	if err := ValidateMode(mode); err != nil {
+		return err
+	}
+
+	if err := setProtectionMode(guildID, mode); err != nil {
+		return err
+	}
+
+	logChange(s, guildID, userID, "Mode", mode)
+	return nil
+}
+
+// ValidateLogChannel checks that channelID can receive the guild's logs.
+func ValidateLogChannel(s *discordgo.Session, guildID, channelID string) error {
+	channel, err := s.State.Channel(channelID)
+	if err != nil || channel.GuildID != guildID || channel.Type != discordgo.ChannelTypeGuildText {
+		return &ValidationError{"Log channel must be a text channel in this server"}
+	}
+	if _, err := getGuildResources(guildID); err != nil {
+		return &ValidationError{"Run setup before changing log channels"}
+	}
+	return nil
+}
+
+// SetLogChannel moves the antinuke (or mod) logs to channelID, creating a
+// new webhook there and removing the old one.
+func SetLogChannel(s *discordgo.Session, guildID, userID, channelID string, mod bool) error {
+	if err := ValidateLogChannel(s, guildID, channelID); err != nil {
+		return err
+	}
+
+	res, err := getGuildResources(guildID)
+	if err != nil {
+		return err
+	}
+
+	oldURL := res.WebhookURL
+	if mod {
+		oldURL = res.ModWebhookURL
+	}
+
+	repairMutex.Lock()
+	err = restoreWebhook(s, guildID, channelID, mod)
+	if err == nil {
+		column := "logs_channel_id"
+		if mod {
+			column = "mod_logs_channel_id"
+		}
+		_, err = db.Exec(fmt.Sprintf("UPDATE antinuke_config SET %s = ? WHERE guild_id = ?", column), channelID, guildID)
+	}
+	repairMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Delete the old webhook only once the new one is saved, so self-defense
+	// doesn't treat its removal as tampering
+	if id, token, ok := parseWebhookURL(oldURL); ok {
+		s.WebhookDeleteWithToken(id, token)
+	}
+
+	setting := "Logs channel"
+	if mod {
+		setting = "Mod logs channel"
+	}
+	logChange(s, guildID, userID, setting, fmt.Sprintf("<#%s>", channelID))
+	return nil
+}
+
+// WhitelistEntry is an active whitelist entry. ExpiresAt is nil for
+// permanent entries.
+type WhitelistEntry struct {
+	UserID    string     `json:"user_id"`
+	AddedBy   string     `json:"added_by"`
+	AddedAt   time.Time  `json:"added_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// parseStoredTime parses a whitelist timestamp, which is RFC 3339 when set
+// by the bot and SQLite's format when left to the column default.
+func parseStoredTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Whitelist returns a guild's active whitelist entries, oldest first.
+func Whitelist(guildID string) ([]WhitelistEntry, error) {
+	rows, err := db.Query(`
+        SELECT user_id, COALESCE(added_by, ''), COALESCE(added_at, ''), COALESCE(expires_at, '')
+        FROM antinuke_whitelist
+        WHERE guild_id = ? AND (expires_at IS NULL OR expires_at > ?)
+        ORDER BY added_at`,
+		guildID, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []WhitelistEntry{}
+	for rows.Next() {
+		var entry WhitelistEntry
+		var addedAt, expiresAt string
+		if err := rows.Scan(&entry.UserID, &entry.AddedBy, &addedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		entry.AddedAt = parseStoredTime(addedAt)
+		if expiresAt != "" {
+			expires := parseStoredTime(expiresAt)
+			entry.ExpiresAt = &expires
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// AddToWhitelist whitelists userID on behalf of addedByID, permanently when
+// duration is 0.
+func AddToWhitelist(s *discordgo.Session, guildID, userID, addedByID string, duration time.Duration) error {
+	if duration < 0 {
+		return &ValidationError{"Duration must be positive"}
+	}
+	if _, err := s.State.Member(guildID, userID); err != nil {
+		if _, err := s.User(userID); err != nil {
+			return &ValidationError{"Unknown user"}
+		}
+	}
+
+	return addUserToWhitelistFor(s, guildID, userID, addedByID, duration)
+}
+
+// RemoveFromWhitelist removes userID from the whitelist on behalf of
+// removedByID.
+func RemoveFromWhitelist(s *discordgo.Session, guildID, userID, removedByID string) error {
+	var count int
+	err := db.QueryRow(`
+        SELECT COUNT(*) FROM antinuke_whitelist
+        WHERE guild_id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)`,
+		guildID, userID, time.Now().UTC().Format(time.RFC3339)).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return &ValidationError{"User is not whitelisted"}
+	}
+
+	return removeUserFromWhitelist(s, guildID, userID, removedByID)
+}
+
+// QuarantinedMembers returns the IDs of the guild's members that have the
+// quarantine role, as seen in the bot's state.
+func QuarantinedMembers(s *discordgo.Session, guildID string) ([]string, error) {
+	roleID := getQuarantineRoleID(guildID)
+	if roleID == "" {
+		return nil, &ValidationError{"Run setup before managing quarantine"}
+	}
+
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.State.RLock()
+	defer s.State.RUnlock()
+
+	members := []string{}
+	for _, member := range guild.Members {
+		for _, id := range member.Roles {
+			if id == roleID {
+				members = append(members, member.User.ID)
+				break
+			}
+		}
+	}
+	return members, nil
+}
+
+// Quarantine quarantines userID on behalf of byID, the same way a
+// detection does.
+func Quarantine(s *discordgo.Session, guildID, userID, byID string) error {
+	if getQuarantineRoleID(guildID) == "" {
+		return &ValidationError{"Run setup before managing quarantine"}
+	}
+	if guild, err := s.State.Guild(guildID); err == nil && guild.OwnerID == userID {
+		return &ValidationError{"The server owner can't be quarantined"}
+	}
+	if _, err := s.State.Member(guildID, userID); err != nil {
+		if _, err := s.GuildMember(guildID, userID); err != nil {
+			return &ValidationError{"User is not a member of this server"}
+		}
+	}
+
+	if err := quarantineMember(s, guildID, userID); err != nil {
+		return err
+	}
+
+	logChange(s, guildID, byID, "Quarantine", fmt.Sprintf("Quarantined <@%s>", userID))
+	return nil
+}
+
+// Release removes the quarantine role from userID on behalf of byID. Roles
+// removed by the quarantine are not restored.
+func Release(s *discordgo.Session, guildID, userID, byID string) error {
+	roleID := getQuarantineRoleID(guildID)
+	if roleID == "" {
+		return &ValidationError{"Run setup before managing quarantine"}
+	}
+
+	if err := s.GuildMemberRoleRemove(guildID, userID, roleID); err != nil {
+		return err
+	}
+
+	logChange(s, guildID, byID, "Quarantine", fmt.Sprintf("Released <@%s>", userID))
+	return nil
+}
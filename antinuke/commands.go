@@ -0,0 +1,327 @@
+package antinuke
+
+import (
+	"fmt"
+	"time"
+
+	"aware/events"
+	"aware/logging"
+	"aware/router"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	manageGuildPermission int64 = discordgo.PermissionManageServer
+	dmPermission                = false
+	minLimit                    = 1.0
+)
+
+// applicationCommands are the slash command equivalents of the
+// `antinuke` and `whitelist` prefix commands.
+var applicationCommands = []*discordgo.ApplicationCommand{
+	{
+		Name:                     "antinuke",
+		Description:              "Configure Anti-Nuke protection",
+		DefaultMemberPermissions: &manageGuildPermission,
+		DMPermission:             &dmPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "setup",
+				Description: "Open the Anti-Nuke setup panel",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionRole,
+						Name:        "quarantine_role",
+						Description: "Existing role to use for quarantined users",
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "config",
+				Description: "Display the current Anti-Nuke configuration",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "limits",
+				Description: "Set action limits before punishment",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "per_minute",
+						Description: "Actions allowed per minute",
+						Required:    true,
+						MinValue:    &minLimit,
+						MaxValue:    99,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "per_hour",
+						Description: "Actions allowed per hour",
+						Required:    true,
+						MinValue:    &minLimit,
+						MaxValue:    999,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "punishment",
+				Description: "Set the punishment for users who trigger protection",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "type",
+						Description: "Punishment type",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Kick", Value: "kick"},
+							{Name: "Ban", Value: "ban"},
+							{Name: "Quarantine", Value: "quarantine"},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:                     "whitelist",
+		Description:              "Manage the Anti-Nuke whitelist",
+		DefaultMemberPermissions: &manageGuildPermission,
+		DMPermission:             &dmPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "add",
+				Description: "Add a user to the whitelist",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Name:        "user",
+						Description: "User to whitelist",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "duration",
+						Description: "Temporary whitelist duration, e.g. 30m or 12h",
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "remove",
+				Description: "Remove a user from the whitelist",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Name:        "user",
+						Description: "User to remove",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "List whitelisted users",
+			},
+		},
+	},
+}
+
+// RegisterCommands overwrites the bot's global application commands.
+func RegisterCommands(s *discordgo.Session) error {
+	_, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, "", applicationCommands)
+	return err
+}
+
+// slashCommand routes `/antinuke` and `/whitelist` to the same logic as the
+// prefix commands and panel buttons.
+func slashCommand(ctx *router.Context) error {
+	s, i := ctx.Session, ctx.Interaction
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		return nil
+	}
+
+	sub := data.Options[0]
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, opt := range sub.Options {
+		options[opt.Name] = opt
+	}
+
+	switch data.Name + " " + sub.Name {
+	case "antinuke setup":
+		handleSetupSlash(s, i, options)
+	case "antinuke config":
+		handleViewConfig(s, i)
+	case "antinuke limits":
+		handleLimitsSlash(s, i, options)
+	case "antinuke punishment":
+		setPunishment(s, i, options["type"].StringValue())
+	case "whitelist add":
+		handleWhitelistAddSlash(s, i, options)
+	case "whitelist remove":
+		handleWhitelistRemoveSlash(s, i, options)
+	case "whitelist list":
+		handleWhitelistList(s, i)
+	}
+	return nil
+}
+
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func handleSetupSlash(s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	if opt, ok := options["quarantine_role"]; ok {
+		role := opt.RoleValue(s, i.GuildID)
+		if role == nil || role.Managed || role.ID == i.GuildID {
+			respondEphemeral(s, i, "That role can't be used as the quarantine role.")
+			return
+		}
+		if reason := unusableQuarantineRole(s, i.GuildID, role); reason != "" {
+			respondEphemeral(s, i, reason)
+			return
+		}
+
+		if err := ensureGuildConfig(i.GuildID); err != nil {
+			respondEphemeral(s, i, "Failed to initialize config: "+err.Error())
+			return
+		}
+
+		// Start Setup reuses the configured role instead of creating one
+		_, err := db.Exec(`
+            UPDATE antinuke_config
+            SET quarantine_role_id = ?
+            WHERE guild_id = ?`,
+			role.ID, i.GuildID,
+		)
+		if err != nil {
+			respondEphemeral(s, i, "Failed to store quarantine role.")
+			return
+		}
+	}
+
+	embed, components := setupPanel()
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: router.BindComponents(components, i.Member.User.ID, i.GuildID),
+		},
+	})
+}
+
+// unusableQuarantineRole explains why an existing role can't become the
+// quarantine role, or returns "". Quarantine denies the role View Channel
+// everywhere, so it must be unused, and the bot must be able to assign it.
+func unusableQuarantineRole(s *discordgo.Session, guildID string, role *discordgo.Role) string {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return "Error fetching guild information."
+	}
+	bot, err := s.State.Member(guildID, s.State.User.ID)
+	if err != nil {
+		return "Error fetching the bot's roles."
+	}
+	if role.Position >= highestRolePosition(guild, bot) {
+		return "The quarantine role must be below the bot's highest role."
+	}
+
+	for _, member := range guild.Members {
+		for _, roleID := range member.Roles {
+			if roleID == role.ID {
+				return "The quarantine role must not be held by any members."
+			}
+		}
+	}
+	return ""
+}
+
+func handleLimitsSlash(s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	apm := int(options["per_minute"].IntValue())
+	aph := int(options["per_hour"].IntValue())
+
+	if err := ValidateLimits(apm, aph); err != nil {
+		respondEphemeral(s, i, err.Error())
+		return
+	}
+
+	if err := ensureGuildConfig(i.GuildID); err != nil {
+		respondEphemeral(s, i, "Failed to initialize config: "+err.Error())
+		return
+	}
+
+	if err := updateLimits(i.GuildID, apm, aph); err != nil {
+		respondEphemeral(s, i, "Failed to update limits in database")
+		return
+	}
+	logging.LogConfigChange(s, i.GuildID, i.Member.User.ID, "Anti-Nuke limits", fmt.Sprintf("%d/minute, %d/hour", apm, aph))
+	events.PublishConfigUpdate(i.GuildID, i.Member.User.ID, "Anti-Nuke limits", fmt.Sprintf("%d/minute, %d/hour", apm, aph))
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{{
+				Title:       "Limits Updated",
+				Description: fmt.Sprintf("Actions per minute: %d\nActions per hour: %d", apm, aph),
+				Color:       0x00ff00,
+			}},
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func handleWhitelistAddSlash(s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	user := options["user"].UserValue(s)
+	if user == nil {
+		respondEphemeral(s, i, "Unknown user.")
+		return
+	}
+
+	var duration time.Duration
+	if opt, ok := options["duration"]; ok {
+		d, err := time.ParseDuration(opt.StringValue())
+		if err != nil || d <= 0 {
+			respondEphemeral(s, i, "Invalid duration, use a value like `30m` or `12h`.")
+			return
+		}
+		duration = d
+	}
+
+	if err := addUserToWhitelistFor(s, i.GuildID, user.ID, i.Member.User.ID, duration); err != nil {
+		respondEphemeral(s, i, "❌ Error adding user to whitelist.")
+		return
+	}
+
+	message := fmt.Sprintf("✅ User <@%s> has been added to the whitelist.", user.ID)
+	if duration > 0 {
+		message = fmt.Sprintf("✅ User <@%s> has been added to the whitelist until <t:%d:f>.", user.ID, time.Now().Add(duration).Unix())
+	}
+	respondEphemeral(s, i, message)
+}
+
+func handleWhitelistRemoveSlash(s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	user := options["user"].UserValue(s)
+	if user == nil {
+		respondEphemeral(s, i, "Unknown user.")
+		return
+	}
+
+	if err := removeUserFromWhitelist(s, i.GuildID, user.ID, i.Member.User.ID); err != nil {
+		respondEphemeral(s, i, "❌ Error removing user from whitelist.")
+		return
+	}
+
+	respondEphemeral(s, i, fmt.Sprintf("✅ User <@%s> has been removed from the whitelist.", user.ID))
+}
@@ -0,0 +1,355 @@
+package antinuke
+
+import (
+	"fmt"
+	"strings"
+
+	"aware/router"
+	"aware/settings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const doctorRepairButton = "doctor_repair_antinuke"
+
+// requiredPermissions are the guild permissions the bot needs to detect and
+// punish nukes and to keep its own setup alive.
+var requiredPermissions = []struct {
+	Name string
+	Bit  int64
+}{
+	{"View Audit Log", discordgo.PermissionViewAuditLogs},
+	{"Manage Channels", discordgo.PermissionManageChannels},
+	{"Manage Roles", discordgo.PermissionManageRoles},
+	{"Manage Webhooks", discordgo.PermissionManageWebhooks},
+	{"Kick Members", discordgo.PermissionKickMembers},
+	{"Ban Members", discordgo.PermissionBanMembers},
+}
+
+type doctorCheck struct {
+	Name       string
+	OK         bool
+	Detail     string
+	Repairable bool
+}
+
+func doctorCommand(ctx *router.Context) error {
+	s := ctx.Session
+	checks := runDoctor(s, ctx.GuildID)
+
+	var components []discordgo.MessageComponent
+	if needsRepair(checks) {
+		components = []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Repair",
+						Style:    discordgo.DangerButton,
+						CustomID: doctorRepairButton,
+					},
+				},
+			},
+		}
+	}
+
+	msg, err := s.ChannelMessageSendComplex(ctx.ChannelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{doctorEmbed(checks)},
+		Components: router.BindComponents(components, ctx.UserID, ctx.GuildID),
+	})
+	router.ExpireMessage(s, msg)
+	return err
+}
+
+func needsRepair(checks []doctorCheck) bool {
+	for _, check := range checks {
+		if !check.OK && check.Repairable {
+			return true
+		}
+	}
+	return false
+}
+
+func doctorEmbed(checks []doctorCheck) *discordgo.MessageEmbed {
+	var report strings.Builder
+	healthy := true
+	for _, check := range checks {
+		icon := "✅"
+		if !check.OK {
+			icon = "❌"
+			healthy = false
+		}
+		report.WriteString(fmt.Sprintf("%s **%s**", icon, check.Name))
+		if check.Detail != "" {
+			report.WriteString(" — " + check.Detail)
+		}
+		report.WriteString("\n")
+	}
+
+	color := 0x00ff00
+	if !healthy {
+		color = 0xff0000
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "Anti-Nuke Doctor",
+		Description: report.String(),
+		Color:       color,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Server Secured by Aware",
+		},
+	}
+}
+
+// memberGuildPermissions computes a member's guild-wide permissions from
+// @everyone and the member's roles, ignoring channel overwrites.
+func memberGuildPermissions(guild *discordgo.Guild, member *discordgo.Member) int64 {
+	if guild.OwnerID == member.User.ID {
+		return discordgo.PermissionAll
+	}
+
+	var perms int64
+	for _, role := range guild.Roles {
+		if role.ID == guild.ID {
+			perms |= role.Permissions
+			continue
+		}
+		for _, roleID := range member.Roles {
+			if roleID == role.ID {
+				perms |= role.Permissions
+				break
+			}
+		}
+	}
+
+	if perms&discordgo.PermissionAdministrator != 0 {
+		return discordgo.PermissionAll
+	}
+	return perms
+}
+
+// highestRolePosition returns the position of the member's highest role.
+func highestRolePosition(guild *discordgo.Guild, member *discordgo.Member) int {
+	highest := 0
+	for _, role := range guild.Roles {
+		for _, roleID := range member.Roles {
+			if roleID == role.ID && role.Position > highest {
+				highest = role.Position
+			}
+		}
+	}
+	return highest
+}
+
+func hasQuarantineOverwrite(channel *discordgo.Channel, roleID string) bool {
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.ID == roleID && overwrite.Deny&discordgo.PermissionViewChannel != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func runDoctor(s *discordgo.Session, guildID string) []doctorCheck {
+	var checks []doctorCheck
+
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		if guild, err = s.Guild(guildID); err != nil {
+			return []doctorCheck{{Name: "Guild", Detail: "Failed to fetch guild information"}}
+		}
+	}
+
+	res, err := getGuildResources(guildID)
+	if err != nil {
+		checks = append(checks, doctorCheck{
+			Name:   "Configuration",
+			Detail: fmt.Sprintf("Not configured, run `%santinuke setup` first", settings.Prefix(guildID)),
+		})
+	} else {
+		checks = append(checks, doctorCheck{Name: "Configuration", OK: true})
+
+		for _, c := range []struct {
+			name, channelID, url string
+		}{
+			{"Logs Channel", res.LogsChannelID, res.WebhookURL},
+			{"Mod Logs Channel", res.ModLogsChannelID, res.ModWebhookURL},
+		} {
+			_, err := s.Channel(c.channelID)
+			channelOK := c.channelID != "" && err == nil
+			check := doctorCheck{Name: c.name, OK: channelOK, Repairable: true}
+			if channelOK {
+				check.Detail = fmt.Sprintf("<#%s>", c.channelID)
+			} else {
+				check.Detail = "Channel is missing"
+			}
+			checks = append(checks, check)
+
+			webhookOK := channelOK && webhookAlive(s, c.url)
+			check = doctorCheck{Name: c.name + " Webhook", OK: webhookOK, Repairable: true}
+			if !webhookOK {
+				check.Detail = "Webhook is missing or invalid"
+			}
+			checks = append(checks, check)
+		}
+
+		roleOK := false
+		for _, role := range guild.Roles {
+			if role.ID == res.QuarantineRoleID {
+				roleOK = true
+			}
+		}
+		check := doctorCheck{Name: "Quarantine Role", OK: roleOK, Repairable: true}
+		if roleOK {
+			check.Detail = fmt.Sprintf("<@&%s>", res.QuarantineRoleID)
+		} else {
+			check.Detail = "Role is missing"
+		}
+		checks = append(checks, check)
+
+		if roleOK {
+			channels, err := s.GuildChannels(guildID)
+			var missing int
+			if err == nil {
+				for _, channel := range channels {
+					if !hasQuarantineOverwrite(channel, res.QuarantineRoleID) {
+						missing++
+					}
+				}
+			}
+			check := doctorCheck{Name: "Quarantine Overwrites", OK: err == nil && missing == 0, Repairable: true}
+			if err != nil {
+				check.Detail = "Failed to fetch channels"
+			} else if missing > 0 {
+				check.Detail = fmt.Sprintf("%d channel(s) missing the deny overwrite", missing)
+			}
+			checks = append(checks, check)
+		}
+	}
+
+	member, err := s.State.Member(guildID, s.State.User.ID)
+	if err != nil {
+		if member, err = s.GuildMember(guildID, s.State.User.ID); err != nil {
+			return append(checks, doctorCheck{Name: "Bot Member", Detail: "Failed to fetch the bot's member"})
+		}
+	}
+
+	perms := memberGuildPermissions(guild, member)
+	var missingPerms []string
+	for _, p := range requiredPermissions {
+		if perms&p.Bit == 0 {
+			missingPerms = append(missingPerms, p.Name)
+		}
+	}
+	check := doctorCheck{Name: "Bot Permissions", OK: len(missingPerms) == 0}
+	if len(missingPerms) > 0 {
+		check.Detail = "Missing: " + strings.Join(missingPerms, ", ")
+	}
+	checks = append(checks, check)
+
+	botPosition := highestRolePosition(guild, member)
+	var above []string
+	for _, role := range guild.Roles {
+		if role.Managed && role.Position == botPosition {
+			continue
+		}
+		if role.Permissions&discordgo.PermissionAdministrator != 0 && role.Position >= botPosition {
+			above = append(above, role.Name)
+		}
+	}
+	check = doctorCheck{Name: "Role Hierarchy", OK: len(above) == 0}
+	if len(above) > 0 {
+		check.Detail = "Move the bot's role above: " + strings.Join(above, ", ")
+	}
+	checks = append(checks, check)
+
+	return checks
+}
+
+// repairSetup fixes every repairable check. It only touches resources that
+// are broken, so running it repeatedly is safe.
+func repairSetup(s *discordgo.Session, guildID string) []string {
+	var repaired []string
+
+	res, err := getGuildResources(guildID)
+	if err != nil {
+		return []string{fmt.Sprintf("Not configured, run `%santinuke setup` first", settings.Prefix(guildID))}
+	}
+
+	repairMutex.Lock()
+	defer repairMutex.Unlock()
+
+	for _, c := range []struct {
+		name, channelID, url string
+		mod                  bool
+	}{
+		{"Logs channel", res.LogsChannelID, res.WebhookURL, false},
+		{"Mod logs channel", res.ModLogsChannelID, res.ModWebhookURL, true},
+	} {
+		if _, err := s.Channel(c.channelID); c.channelID == "" || err != nil {
+			if err := restoreLogChannel(s, guildID, c.mod); err != nil {
+				repaired = append(repaired, fmt.Sprintf("❌ %s: %v", c.name, err))
+			} else {
+				repaired = append(repaired, fmt.Sprintf("✅ %s recreated", c.name))
+			}
+			continue
+		}
+
+		if !webhookAlive(s, c.url) {
+			if err := restoreWebhook(s, guildID, c.channelID, c.mod); err != nil {
+				repaired = append(repaired, fmt.Sprintf("❌ %s webhook: %v", c.name, err))
+			} else {
+				repaired = append(repaired, fmt.Sprintf("✅ %s webhook recreated", c.name))
+			}
+		}
+	}
+
+	if _, err := s.State.Role(guildID, res.QuarantineRoleID); res.QuarantineRoleID == "" || err != nil {
+		if err := restoreQuarantineRole(s, guildID); err != nil {
+			repaired = append(repaired, fmt.Sprintf("❌ Quarantine role: %v", err))
+		} else {
+			repaired = append(repaired, "✅ Quarantine role recreated")
+		}
+	} else {
+		channels, err := s.GuildChannels(guildID)
+		if err == nil {
+			fixed := 0
+			for _, channel := range channels {
+				if hasQuarantineOverwrite(channel, res.QuarantineRoleID) {
+					continue
+				}
+				err := denyQuarantineView(s, channel.ID, res.QuarantineRoleID)
+				if err == nil {
+					fixed++
+				}
+			}
+			if fixed > 0 {
+				repaired = append(repaired, fmt.Sprintf("✅ Quarantine overwrite added to %d channel(s)", fixed))
+			}
+		}
+	}
+
+	return repaired
+}
+
+func handleDoctorRepair(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+
+	repaired := repairSetup(s, i.GuildID)
+	checks := runDoctor(s, i.GuildID)
+
+	embeds := []*discordgo.MessageEmbed{doctorEmbed(checks)}
+	if len(repaired) > 0 {
+		embeds = append(embeds, &discordgo.MessageEmbed{
+			Title:       "Repairs",
+			Description: strings.Join(repaired, "\n"),
+			Color:       0x3498db,
+		})
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &embeds,
+		Components: &[]discordgo.MessageComponent{},
+	})
+}
@@ -2,10 +2,11 @@ package antinuke
 
 import (
     "fmt"
-    "database/sql"
     "time"
-	"strings"
     "sync"
+
+    "aware/logging"
+
     "github.com/bwmarrin/discordgo"
 )
 
@@ -22,12 +23,14 @@ type ActionCount struct {
 }
 
 func InitEvents(s *discordgo.Session) {
-    // Set required intents first
-    s.Identify.Intents = discordgo.IntentsGuildWebhooks |
+    // Add required intents first, keeping any the caller already set
+    s.Identify.Intents |= discordgo.IntentsGuildWebhooks |
         discordgo.IntentsGuildMembers |
         discordgo.IntentsGuildBans |
         discordgo.IntentsGuilds |
-        discordgo.IntentsGuildMessages
+        discordgo.IntentsGuildMessages |
+        discordgo.IntentsGuildVoiceStates |
+        discordgo.IntentsGuildInvites
 
     // Register handlers after setting intents
     s.AddHandler(handleChannelDelete)
@@ -36,12 +39,29 @@ func InitEvents(s *discordgo.Session) {
     s.AddHandler(handleMemberRemove)
     s.AddHandler(handleWebhookUpdate)
     s.AddHandler(handleGuildUpdate)
+    s.AddHandler(handleInviteCreate)
+    s.AddHandler(handleInviteDelete)
+    logging.OnForcedDisconnect(watchesDisconnects, handleVoiceDisconnect)
+
+    // Self-defense: repair and punish tampering with the bot's own setup
+    s.AddHandler(handleSetupGuildCreate)
+    s.AddHandler(handleSetupChannelDelete)
+    s.AddHandler(handleSetupRoleDelete)
+    s.AddHandler(handleSetupWebhooksUpdate)
+    s.AddHandler(handleBotRoleUpdate)
+
+    // Quarantine overwrite enforcement for new and modified channels
+    s.AddHandler(handleQuarantineChannelCreate)
+    s.AddHandler(handleQuarantineThreadCreate)
+    s.AddHandler(handleQuarantineReady)
 }
 
 func isWhitelisted(guildID, userID string) bool {
     var count int
-    err := db.QueryRow("SELECT COUNT(*) FROM antinuke_whitelist WHERE guild_id = ? AND user_id = ?", 
-        guildID, userID).Scan(&count)
+    err := db.QueryRow(`
+        SELECT COUNT(*) FROM antinuke_whitelist
+        WHERE guild_id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)`,
+        guildID, userID, time.Now().UTC().Format(time.RFC3339)).Scan(&count)
     
     if err != nil {
         fmt.Printf("Error checking whitelist: %v\n", err)
@@ -65,6 +85,38 @@ func getAuditLogUser(s *discordgo.Session, guildID string, actionType discordgo.
     return auditLog.AuditLogEntries[0].UserID, nil
 }
 
+// inviteDeleteWindow is how old an invite delete audit log entry may be and
+// still be attributed to the INVITE_DELETE being handled.
+const inviteDeleteWindow = 15 * time.Second
+
+// getInviteDeleter returns who deleted the invite with code. Invites that
+// expire or run out of uses are deleted without an audit log entry, so
+// only a recent entry for the same code counts.
+func getInviteDeleter(s *discordgo.Session, guildID, code string) (string, bool) {
+    auditLog, err := s.GuildAuditLog(guildID, "", "", int(discordgo.AuditLogActionInviteDelete), 5)
+    if err != nil {
+        return "", false
+    }
+
+    for _, entry := range auditLog.AuditLogEntries {
+        created, err := discordgo.SnowflakeTimestamp(entry.ID)
+        if err != nil || time.Since(created) > inviteDeleteWindow {
+            continue
+        }
+
+        matches := entry.TargetID == code
+        for _, change := range entry.Changes {
+            if change.Key != nil && *change.Key == discordgo.AuditLogChangeKeyCode && change.OldValue == code {
+                matches = true
+            }
+        }
+        if matches {
+            return entry.UserID, true
+        }
+    }
+    return "", false
+}
+
 func verifyWebhookPermissions(s *discordgo.Session, channelID string) error {
     perms, err := s.State.UserChannelPermissions(s.State.User.ID, channelID)
     if err != nil {
@@ -78,32 +130,6 @@ func verifyWebhookPermissions(s *discordgo.Session, channelID string) error {
 }
 
 
-func sendWebhookEmbed(webhookURL string, embed *discordgo.MessageEmbed) error {
-    if webhookURL == "" {
-        return fmt.Errorf("webhook URL is empty")
-    }
-
-    webhook, err := discordgo.New("")
-    if err != nil {
-        return fmt.Errorf("failed to create webhook session: %v", err)
-    }
-
-    parts := strings.Split(webhookURL, "/")
-    if len(parts) < 2 {
-        return fmt.Errorf("invalid webhook URL format")
-    }
-
-    webhookID := parts[len(parts)-2]
-    webhookToken := parts[len(parts)-1]
-
-    _, err = webhook.WebhookExecute(webhookID, webhookToken, true, &discordgo.WebhookParams{
-        Embeds: []*discordgo.MessageEmbed{embed},
-        Username: "Mod Logs",
-    })
-    return err
-}
-
-
 func createLogEmbed(userID, action, reason string, color int) *discordgo.MessageEmbed {
     return &discordgo.MessageEmbed{
         Title: "Anti-Nuke Detection",
@@ -139,28 +165,9 @@ func sendLogs(s *discordgo.Session, guildID, userID, action, reason string) {
         reason = fmt.Sprintf("%s (Action allowed - User is whitelisted)", reason)
     }
     
-    var webhookURL, modWebhookURL string
-    err := db.QueryRow(`
-        SELECT webhook_id, mod_webhook_id 
-        FROM antinuke_config 
-        WHERE guild_id = ?`, guildID).Scan(&webhookURL, &modWebhookURL)
-    if err != nil {
-        if err == sql.ErrNoRows {
-            fmt.Printf("No webhook configuration found for guild %s\n", guildID)
-        } else {
-            fmt.Printf("Database error getting webhooks: %v\n", err)
-        }
-        return
-    }
-
-    if webhookURL == "" || modWebhookURL == "" {
-        fmt.Printf("Missing webhook URLs for guild %s\n", guildID)
-        return
-    }
-
     // Send logs with retries
     for i := 0; i < 3; i++ {
-        if err := sendWebhookEmbed(webhookURL, createLogEmbed(userID, action, reason, 0xff6b6b)); err != nil {
+        if err := logging.Send(s, guildID, logging.CategoryAntinuke, createLogEmbed(userID, action, reason, 0xff6b6b)); err != nil {
             if i == 2 {
                 fmt.Printf("Final attempt to send antinuke log failed: %v\n", err)
             }
@@ -174,7 +181,7 @@ func sendLogs(s *discordgo.Session, guildID, userID, action, reason string) {
     if !isUserWhitelisted {
         punishment := getPunishmentType(guildID)
         for i := 0; i < 3; i++ {
-            if err := sendWebhookEmbed(modWebhookURL, createModLogEmbed(userID, action, punishment)); err != nil {
+            if err := logging.Send(s, guildID, logging.CategoryModeration, createModLogEmbed(userID, action, punishment)); err != nil {
                 if i == 2 {
                     fmt.Printf("Final attempt to send mod log failed: %v\n", err)
                 }
@@ -186,6 +193,21 @@ func sendLogs(s *discordgo.Session, guildID, userID, action, reason string) {
     }
 }
 
+// setupLogDestination makes the webhooks created by setup the default
+// destination for antinuke and moderation logs.
+func setupLogDestination(mod bool) func(guildID string) logging.Destination {
+    return func(guildID string) logging.Destination {
+        res, err := getGuildResources(guildID)
+        if err != nil {
+            return logging.Destination{}
+        }
+        if mod {
+            return logging.Destination{ChannelID: res.ModLogsChannelID, WebhookURL: res.ModWebhookURL}
+        }
+        return logging.Destination{ChannelID: res.LogsChannelID, WebhookURL: res.WebhookURL}
+    }
+}
+
 func checkLimits(guildID, userID string) bool {
     // Skip limit checks for whitelisted users
     if isWhitelisted(guildID, userID) {
@@ -216,17 +238,7 @@ func checkLimits(guildID, userID string) bool {
         count.LastHour = now
     }
 
-    var apm, aph int
-    err := db.QueryRow(`
-        SELECT actions_per_minute, actions_per_hour 
-        FROM antinuke_config 
-        WHERE guild_id = ?`, guildID).Scan(&apm, &aph)
-    
-    if err != nil {
-        apm = 2
-        aph = 10
-    }
-    
+    apm, aph := getLimits(guildID)
     if count.MinuteCount >= apm || count.HourCount >= aph {
         return false
     }   
@@ -237,6 +249,19 @@ func checkLimits(guildID, userID string) bool {
     return true
 }
 
+// getActionCount returns how many actions were counted for the user in the
+// current minute and hour windows.
+func getActionCount(guildID, userID string) (int, int) {
+    actionMutex.RLock()
+    defer actionMutex.RUnlock()
+
+    count, exists := actionCounts[fmt.Sprintf("%s:%s", guildID, userID)]
+    if !exists {
+        return 0, 0
+    }
+    return count.MinuteCount, count.HourCount
+}
+
 
 func getPunishmentType(guildID string) string {
     var punishType string
@@ -251,7 +276,7 @@ func getPunishmentType(guildID string) string {
     return punishType
 }
 
-func applyPunishment(s *discordgo.Session, guildID, userID, reason string) {
+func applyPunishment(s *discordgo.Session, guildID, userID, action, reason string) {
     punishType := getPunishmentType(guildID)
     
     // Add prefix to reason
@@ -261,59 +286,34 @@ func applyPunishment(s *discordgo.Session, guildID, userID, reason string) {
     case "ban":
         if err := s.GuildBanCreateWithReason(guildID, userID, reason, 0); err != nil {
             fmt.Printf("Failed to ban user %s: %v\n", userID, err)
+            recordIncident(guildID, userID, EventPunishment, action, fmt.Sprintf("Failed to ban: %v", err))
             return
         }
     
     case "kick":
         if err := s.GuildMemberDeleteWithReason(guildID, userID, reason); err != nil {
             fmt.Printf("Failed to kick user %s: %v\n", userID, err)
+            recordIncident(guildID, userID, EventPunishment, action, fmt.Sprintf("Failed to kick: %v", err))
             return
         }
     
     case "quarantine":
-        // Get quarantine role ID
-        var quarantineRoleID string
-        err := db.QueryRow(`
-            SELECT quarantine_role_id 
-            FROM antinuke_config 
-            WHERE guild_id = ?`, guildID).Scan(&quarantineRoleID)
-        
-        if err != nil {
-            fmt.Printf("Failed to get quarantine role: %v\n", err)
-            return
-        }
-
-        // Get member information
-        member, err := s.GuildMember(guildID, userID)
-        if err != nil {
-            fmt.Printf("Failed to get member info: %v\n", err)
-            return
-        }
-
-        // Store original roles before removing
-        originalRoles := member.Roles
-
-        // Remove all roles
-        for _, roleID := range originalRoles {
-            if err := s.GuildMemberRoleRemove(guildID, userID, roleID); err != nil {
-                fmt.Printf("Failed to remove role %s: %v\n", roleID, err)
-                continue
-            }
-        }
-
-        if err := s.GuildMemberRoleAdd(guildID, userID, quarantineRoleID); err != nil {
-            fmt.Printf("Failed to add quarantine role: %v\n", err)
-            for _, roleID := range originalRoles {
-                s.GuildMemberRoleAdd(guildID, userID, roleID)
-            }
+        if err := quarantineMember(s, guildID, userID); err != nil {
+            fmt.Printf("Failed to quarantine user %s: %v\n", userID, err)
+            recordIncident(guildID, userID, EventPunishment, action, fmt.Sprintf("Failed to quarantine: %v", err))
             return
         }
     }
 
+    recordIncident(guildID, userID, EventPunishment, action, punishType)
     sendLogs(s, guildID, userID, "Punishment Applied", reason)
 }
 
 func handleRoleDelete(s *discordgo.Session, e *discordgo.GuildRoleDelete) {
+    if !protectionEnabled(e.GuildID) {
+        return
+    }
+
     userID, err := getAuditLogUser(s, e.GuildID, discordgo.AuditLogActionRoleDelete)
     if err != nil {
         fmt.Printf("Error getting audit log user: %v\n", err)
@@ -327,12 +327,15 @@ func handleRoleDelete(s *discordgo.Session, e *discordgo.GuildRoleDelete) {
 
     if !checkLimits(e.GuildID, userID) {
         reason := "Mass Role Deletion Detected"
-        applyPunishment(s, e.GuildID, userID, reason)
-        sendLogs(s, e.GuildID, userID, "Role Deletion", reason)
+        respondToDetection(s, e.GuildID, userID, "Role Deletion", reason)
     }
 }
 
 func handleChannelDelete(s *discordgo.Session, e *discordgo.ChannelDelete) {
+    if !protectionEnabled(e.GuildID) {
+        return
+    }
+
     userID, err := getAuditLogUser(s, e.GuildID, discordgo.AuditLogActionChannelDelete)
     if err != nil {
         return
@@ -345,12 +348,15 @@ func handleChannelDelete(s *discordgo.Session, e *discordgo.ChannelDelete) {
 
     if !checkLimits(e.GuildID, userID) {
         reason := "Mass Channel Deletion Detected"
-        applyPunishment(s, e.GuildID, userID, reason)
-        sendLogs(s, e.GuildID, userID, "Channel Deletion", reason)
+        respondToDetection(s, e.GuildID, userID, "Channel Deletion", reason)
     }
 }
 
 func handleWebhookUpdate(s *discordgo.Session, e *discordgo.WebhooksUpdate) {
+    if !protectionEnabled(e.GuildID) {
+        return
+    }
+
     userID, err := getAuditLogUser(s, e.GuildID, discordgo.AuditLogActionWebhookCreate)
     if err != nil {
         return
@@ -363,12 +369,15 @@ func handleWebhookUpdate(s *discordgo.Session, e *discordgo.WebhooksUpdate) {
 
     if !checkLimits(e.GuildID, userID) {
         reason := "Mass Webhook Creation/Deletion Detected"
-        applyPunishment(s, e.GuildID, userID, reason)
-        sendLogs(s, e.GuildID, userID, "Webhook Update", reason)
+        respondToDetection(s, e.GuildID, userID, "Webhook Update", reason)
     }
 }
 
 func handleGuildUpdate(s *discordgo.Session, e *discordgo.GuildUpdate) {
+    if !protectionEnabled(e.Guild.ID) {
+        return
+    }
+
     userID, err := getAuditLogUser(s, e.Guild.ID, discordgo.AuditLogActionGuildUpdate)
     if err != nil {
         return
@@ -381,12 +390,15 @@ func handleGuildUpdate(s *discordgo.Session, e *discordgo.GuildUpdate) {
 
     if !checkLimits(e.Guild.ID, userID) {
         reason := "Suspicious Guild Updates Detected"
-        applyPunishment(s, e.Guild.ID, userID, reason)
-        sendLogs(s, e.Guild.ID, userID, "Guild Update", reason)
+        respondToDetection(s, e.Guild.ID, userID, "Guild Update", reason)
     }
 }
 
 func handleBanAdd(s *discordgo.Session, e *discordgo.GuildBanAdd) {
+    if !protectionEnabled(e.GuildID) {
+        return
+    }
+
     userID, err := getAuditLogUser(s, e.GuildID, discordgo.AuditLogActionMemberBanAdd)
     if err != nil {
         return
@@ -399,12 +411,15 @@ func handleBanAdd(s *discordgo.Session, e *discordgo.GuildBanAdd) {
 
     if !checkLimits(e.GuildID, userID) {
         reason := "Mass Ban Detected"
-        applyPunishment(s, e.GuildID, userID, reason)
-        sendLogs(s, e.GuildID, userID, "Member Ban", reason)
+        respondToDetection(s, e.GuildID, userID, "Member Ban", reason)
     }
 }
 
 func handleMemberRemove(s *discordgo.Session, e *discordgo.GuildMemberRemove) {
+    if !protectionEnabled(e.GuildID) {
+        return
+    }
+
     userID, err := getAuditLogUser(s, e.GuildID, discordgo.AuditLogActionMemberKick)
     if err != nil {
         return
@@ -417,7 +432,70 @@ func handleMemberRemove(s *discordgo.Session, e *discordgo.GuildMemberRemove) {
 
     if !checkLimits(e.GuildID, userID) {
         reason := "Mass Kick Detected"
-        applyPunishment(s, e.GuildID, userID, reason)
-        sendLogs(s, e.GuildID, userID, "Member Kick", reason)
+        respondToDetection(s, e.GuildID, userID, "Member Kick", reason)
+    }
+}
+
+// watchesDisconnects reports whether forced voice disconnects should be
+// looked up for the guild. Protection defaults to on, so it also requires
+// setup, to keep the audit log lookups to guilds that use antinuke.
+func watchesDisconnects(guildID string) bool {
+    if !protectionEnabled(guildID) {
+        return false
+    }
+    res, err := getGuildResources(guildID)
+    return err == nil && res.LogsChannelID != ""
+}
+
+func handleVoiceDisconnect(s *discordgo.Session, guildID, actorID, userID string) {
+    if !protectionEnabled(guildID) {
+        return
+    }
+
+    // Skip if user is whitelisted
+    if isWhitelisted(guildID, actorID) {
+        return
+    }
+
+    if !checkLimits(guildID, actorID) {
+        reason := "Mass Voice Disconnect Detected"
+        respondToDetection(s, guildID, actorID, "Voice Disconnect", reason)
+    }
+}
+
+func handleInviteCreate(s *discordgo.Session, e *discordgo.InviteCreate) {
+    if !protectionEnabled(e.GuildID) || e.Inviter == nil {
+        return
+    }
+
+    // Skip if user is whitelisted
+    if isWhitelisted(e.GuildID, e.Inviter.ID) {
+        return
+    }
+
+    if !checkLimits(e.GuildID, e.Inviter.ID) {
+        reason := "Mass Invite Creation Detected"
+        respondToDetection(s, e.GuildID, e.Inviter.ID, "Invite Create", reason)
+    }
+}
+
+func handleInviteDelete(s *discordgo.Session, e *discordgo.InviteDelete) {
+    if !protectionEnabled(e.GuildID) {
+        return
+    }
+
+    userID, ok := getInviteDeleter(s, e.GuildID, e.Code)
+    if !ok {
+        return
+    }
+
+    // Skip if user is whitelisted
+    if isWhitelisted(e.GuildID, userID) {
+        return
+    }
+
+    if !checkLimits(e.GuildID, userID) {
+        reason := "Mass Invite Deletion Detected"
+        respondToDetection(s, e.GuildID, userID, "Invite Delete", reason)
     }
 }
@@ -0,0 +1,302 @@
+package antinuke
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"aware/events"
+)
+
+// Incident event types.
+const (
+	EventDetection  = "detection"
+	EventPunishment = "punishment"
+	EventRollback   = "rollback"
+)
+
+// eventTypes maps incident event types to the bus events they publish.
+var eventTypes = map[string]string{
+	EventDetection:  events.TypeDetection,
+	EventPunishment: events.TypePunishment,
+	EventRollback:   events.TypeRollback,
+}
+
+// incidentWindow is how long after an actor's last event further events are
+// grouped into the same incident.
+const incidentWindow = 10 * time.Minute
+
+// ErrIncidentNotFound is returned by GetIncident for unknown incidents.
+var ErrIncidentNotFound = errors.New("incident not found")
+
+// Incident groups the detections, punishments and rollbacks caused by one
+// actor in a burst of activity.
+type Incident struct {
+	ID         int64           `json:"id"`
+	GuildID    string          `json:"guild_id"`
+	ActorID    string          `json:"actor_id"`
+	Action     string          `json:"action"`
+	Reason     string          `json:"reason"`
+	EventCount int             `json:"event_count"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	Events     []IncidentEvent `json:"events,omitempty"`
+}
+
+// IncidentEvent is a single step of an incident.
+type IncidentEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Action    string    `json:"action"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IncidentFilter narrows ListIncidents. Zero fields match everything.
+type IncidentFilter struct {
+	ActorID string
+	Action  string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+func createIncidentTables() {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS antinuke_incidents (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            guild_id TEXT NOT NULL,
+            actor_id TEXT NOT NULL,
+            action TEXT NOT NULL,
+            reason TEXT,
+            created_at TEXT NOT NULL,
+            updated_at TEXT NOT NULL
+        )
+    `)
+	if err != nil {
+		fmt.Printf("Error creating incidents table: %v\n", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS antinuke_incident_events (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            incident_id INTEGER NOT NULL REFERENCES antinuke_incidents(id) ON DELETE CASCADE,
+            type TEXT NOT NULL,
+            action TEXT NOT NULL,
+            detail TEXT,
+            created_at TEXT NOT NULL
+        )
+    `)
+	if err != nil {
+		fmt.Printf("Error creating incident events table: %v\n", err)
+	}
+
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_antinuke_incidents_guild ON antinuke_incidents (guild_id, updated_at)")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_antinuke_incident_events_incident ON antinuke_incident_events (incident_id)")
+}
+
+func formatIncidentTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// recordIncident adds an event to the actor's open incident, opening a new
+// one when the actor has had no events within incidentWindow.
+func recordIncident(guildID, actorID, eventType, action, detail string) {
+	now := time.Now()
+	stamp := formatIncidentTime(now)
+
+	tx, err := db.Begin()
+	if err != nil {
+		fmt.Printf("Failed to record incident: %v\n", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var incidentID int64
+	err = tx.QueryRow(`
+        SELECT id FROM antinuke_incidents
+        WHERE guild_id = ? AND actor_id = ? AND updated_at >= ?
+        ORDER BY updated_at DESC LIMIT 1`,
+		guildID, actorID, formatIncidentTime(now.Add(-incidentWindow))).Scan(&incidentID)
+
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := tx.Exec(`
+            INSERT INTO antinuke_incidents (guild_id, actor_id, action, reason, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?)`,
+			guildID, actorID, action, detail, stamp, stamp)
+		if err != nil {
+			fmt.Printf("Failed to open incident: %v\n", err)
+			return
+		}
+		incidentID, _ = res.LastInsertId()
+	case err != nil:
+		fmt.Printf("Failed to find open incident: %v\n", err)
+		return
+	default:
+		if _, err := tx.Exec("UPDATE antinuke_incidents SET updated_at = ? WHERE id = ?", stamp, incidentID); err != nil {
+			fmt.Printf("Failed to update incident: %v\n", err)
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO antinuke_incident_events (incident_id, type, action, detail, created_at)
+        VALUES (?, ?, ?, ?, ?)`,
+		incidentID, eventType, action, detail, stamp)
+	if err != nil {
+		fmt.Printf("Failed to record incident event: %v\n", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		fmt.Printf("Failed to record incident: %v\n", err)
+		return
+	}
+
+	events.Publish(guildID, eventTypes[eventType], events.IncidentData{
+		IncidentID: incidentID,
+		ActorID:    actorID,
+		Action:     action,
+		Detail:     detail,
+	})
+}
+
+// ListIncidents returns a guild's incidents matching filter, newest first.
+func ListIncidents(guildID string, filter IncidentFilter) ([]Incident, error) {
+	where := []string{"i.guild_id = ?"}
+	args := []interface{}{guildID}
+
+	if filter.ActorID != "" {
+		where = append(where, "i.actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM antinuke_incident_events f WHERE f.incident_id = i.id AND f.action = ?)")
+		args = append(args, filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "i.updated_at >= ?")
+		args = append(args, formatIncidentTime(filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "i.created_at < ?")
+		args = append(args, formatIncidentTime(filter.Until))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(`
+        SELECT i.id, i.guild_id, i.actor_id, i.action, COALESCE(i.reason, ''), i.created_at, i.updated_at,
+               (SELECT COUNT(*) FROM antinuke_incident_events e WHERE e.incident_id = i.id)
+        FROM antinuke_incidents i
+        WHERE `+strings.Join(where, " AND ")+`
+        ORDER BY i.updated_at DESC, i.id DESC
+        LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incidents := []Incident{}
+	for rows.Next() {
+		incident, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, incident)
+	}
+	return incidents, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanIncident(row scanner) (Incident, error) {
+	var incident Incident
+	var createdAt, updatedAt string
+	err := row.Scan(&incident.ID, &incident.GuildID, &incident.ActorID, &incident.Action, &incident.Reason,
+		&createdAt, &updatedAt, &incident.EventCount)
+	incident.CreatedAt = parseStoredTime(createdAt)
+	incident.UpdatedAt = parseStoredTime(updatedAt)
+	return incident, err
+}
+
+// GetIncident returns one of a guild's incidents with all of its events,
+// oldest first.
+func GetIncident(guildID string, id int64) (*Incident, error) {
+	incident, err := scanIncident(db.QueryRow(`
+        SELECT i.id, i.guild_id, i.actor_id, i.action, COALESCE(i.reason, ''), i.created_at, i.updated_at,
+               (SELECT COUNT(*) FROM antinuke_incident_events e WHERE e.incident_id = i.id)
+        FROM antinuke_incidents i
+        WHERE i.guild_id = ? AND i.id = ?`, guildID, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrIncidentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+        SELECT id, type, action, COALESCE(detail, ''), created_at
+        FROM antinuke_incident_events
+        WHERE incident_id = ?
+        ORDER BY created_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incident.Events = []IncidentEvent{}
+	for rows.Next() {
+		var event IncidentEvent
+		var createdAt string
+		if err := rows.Scan(&event.ID, &event.Type, &event.Action, &event.Detail, &createdAt); err != nil {
+			return nil, err
+		}
+		event.CreatedAt = parseStoredTime(createdAt)
+		incident.Events = append(incident.Events, event)
+	}
+	return &incident, rows.Err()
+}
+
+// IncidentActions returns the distinct actions recorded for a guild, for
+// filtering the timeline.
+func IncidentActions(guildID string) ([]string, error) {
+	rows, err := db.Query(`
+        SELECT DISTINCT e.action
+        FROM antinuke_incident_events e
+        JOIN antinuke_incidents i ON i.id = e.incident_id
+        WHERE i.guild_id = ?
+        ORDER BY e.action`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []string
+	for rows.Next() {
+		var action string
+		if err := rows.Scan(&action); err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
+
+// recordRollback records the outcome of undoing what an actor changed.
+func recordRollback(guildID, actorID, action, what string, err error) {
+	detail := fmt.Sprintf("Rolled back: %s", what)
+	if err != nil {
+		detail = fmt.Sprintf("Rollback failed (%s): %v", what, err)
+	}
+	recordIncident(guildID, actorID, EventRollback, action, detail)
+}
@@ -0,0 +1,153 @@
+package antinuke
+
+import (
+	"fmt"
+	"time"
+
+	"aware/events"
+	"aware/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Protection modes derived from the enabled and monitor_mode columns.
+const (
+	modeActive   = "active"
+	modeMonitor  = "monitor"
+	modeDisabled = "disabled"
+)
+
+const (
+	enableModeButton  = "enable_antinuke"
+	monitorModeButton = "monitor_antinuke"
+	disableModeButton = "disable_antinuke"
+)
+
+// getProtectionMode returns the guild's protection mode. Guilds without a
+// config row keep the historical behaviour and are treated as active.
+func getProtectionMode(guildID string) string {
+	var enabled, monitor bool
+	err := db.QueryRow(`
+        SELECT COALESCE(enabled, true), COALESCE(monitor_mode, false)
+        FROM antinuke_config
+        WHERE guild_id = ?`, guildID).Scan(&enabled, &monitor)
+	if err != nil {
+		return modeActive
+	}
+
+	switch {
+	case !enabled:
+		return modeDisabled
+	case monitor:
+		return modeMonitor
+	default:
+		return modeActive
+	}
+}
+
+func protectionEnabled(guildID string) bool {
+	return getProtectionMode(guildID) != modeDisabled
+}
+
+func setProtectionMode(guildID, mode string) error {
+	if err := ensureGuildConfig(guildID); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+        UPDATE antinuke_config
+        SET enabled = ?, monitor_mode = ?
+        WHERE guild_id = ?`,
+		mode != modeDisabled, mode == modeMonitor, guildID,
+	)
+	return err
+}
+
+// respondToDetection punishes the user, or in monitor mode only records
+// what would have happened.
+func respondToDetection(s *discordgo.Session, guildID, userID, action, reason string) {
+	if getProtectionMode(guildID) == modeMonitor {
+		recordIncident(guildID, userID, EventDetection, action, reason+" (monitor mode)")
+		sendMonitorLog(s, guildID, userID, action, reason)
+		return
+	}
+
+	recordIncident(guildID, userID, EventDetection, action, reason)
+	applyPunishment(s, guildID, userID, action, reason)
+	sendLogs(s, guildID, userID, action, reason)
+}
+
+func createMonitorEmbed(guildID, userID, action, reason string) *discordgo.MessageEmbed {
+	minute, hour := getActionCount(guildID, userID)
+
+	apm, aph := getLimits(guildID)
+
+	return &discordgo.MessageEmbed{
+		Title: "Anti-Nuke Detection (Monitor Mode)",
+		Description: fmt.Sprintf("**User:** <@%s>\n**Action:** %s\n**Reason:** %s",
+			userID, action, reason),
+		Color: 0xf1c40f,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Last Minute",
+				Value:  fmt.Sprintf("%d / %d actions", minute, apm),
+				Inline: true,
+			},
+			{
+				Name:   "Last Hour",
+				Value:  fmt.Sprintf("%d / %d actions", hour, aph),
+				Inline: true,
+			},
+			{
+				Name:   "Would Apply",
+				Value:  getPunishmentType(guildID),
+				Inline: true,
+			},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Monitor mode: no punishment or rollback applied",
+		},
+	}
+}
+
+func sendMonitorLog(s *discordgo.Session, guildID, userID, action, reason string) {
+	if err := logging.Send(s, guildID, logging.CategoryAntinuke, createMonitorEmbed(guildID, userID, action, reason)); err != nil {
+		fmt.Printf("Failed to send monitor log: %v\n", err)
+	}
+}
+
+func handleModeSetup(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	var mode, description string
+	switch i.MessageComponentData().CustomID {
+	case enableModeButton:
+		mode, description = modeActive, "Detections are punished and logged."
+	case monitorModeButton:
+		mode, description = modeMonitor, "Detections are logged only, no punishment is applied."
+	case disableModeButton:
+		mode, description = modeDisabled, "All detectors are turned off."
+	default:
+		return
+	}
+
+	if err := setProtectionMode(i.GuildID, mode); err != nil {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: stringPtr("Failed to update protection mode: " + err.Error()),
+		})
+		return
+	}
+	logging.LogConfigChange(s, i.GuildID, i.Member.User.ID, "Anti-Nuke mode", mode)
+	events.PublishConfigUpdate(i.GuildID, i.Member.User.ID, "Anti-Nuke mode", mode)
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{{
+			Title:       "Protection Mode Updated",
+			Description: fmt.Sprintf("Mode set to: **%s**\n%s", mode, description),
+			Color:       0x00ff00,
+		}},
+	})
+}
@@ -0,0 +1,169 @@
+package antinuke
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// quarantineReconcileInterval is how often every configured guild is checked
+// for channels that lost the quarantine deny overwrite.
+const quarantineReconcileInterval = 10 * time.Minute
+
+var reconcileOnce sync.Once
+
+func getQuarantineRoleID(guildID string) string {
+	var roleID string
+	err := db.QueryRow(`
+        SELECT COALESCE(quarantine_role_id, '')
+        FROM antinuke_config
+        WHERE guild_id = ?`, guildID).Scan(&roleID)
+	if err != nil {
+		return ""
+	}
+	return roleID
+}
+
+// quarantineMember strips a member's roles and gives them the quarantine
+// role, putting the roles back if the quarantine role can't be added.
+func quarantineMember(s *discordgo.Session, guildID, userID string) error {
+	roleID := getQuarantineRoleID(guildID)
+	if roleID == "" {
+		return fmt.Errorf("no quarantine role configured")
+	}
+
+	member, err := s.GuildMember(guildID, userID)
+	if err != nil {
+		return fmt.Errorf("getting member: %w", err)
+	}
+
+	originalRoles := member.Roles
+	for _, id := range originalRoles {
+		if id == roleID {
+			continue
+		}
+		if err := s.GuildMemberRoleRemove(guildID, userID, id); err != nil {
+			fmt.Printf("Failed to remove role %s: %v\n", id, err)
+		}
+	}
+
+	if err := s.GuildMemberRoleAdd(guildID, userID, roleID); err != nil {
+		for _, id := range originalRoles {
+			s.GuildMemberRoleAdd(guildID, userID, id)
+		}
+		return fmt.Errorf("adding quarantine role: %w", err)
+	}
+	return nil
+}
+
+func denyQuarantineView(s *discordgo.Session, channelID, roleID string) error {
+	return s.ChannelPermissionSet(
+		channelID,
+		roleID,
+		discordgo.PermissionOverwriteTypeRole,
+		0,
+		discordgo.PermissionViewChannel,
+	)
+}
+
+func handleQuarantineChannelCreate(s *discordgo.Session, c *discordgo.ChannelCreate) {
+	roleID := getQuarantineRoleID(c.GuildID)
+	if roleID == "" {
+		return
+	}
+
+	if err := denyQuarantineView(s, c.ID, roleID); err != nil {
+		fmt.Printf("Error setting permissions for new channel %s: %v\n", c.ID, err)
+	}
+}
+
+// handleQuarantineThreadCreate covers threads. Threads can't carry their own
+// overwrites and inherit the parent's, so the parent is checked instead.
+func handleQuarantineThreadCreate(s *discordgo.Session, t *discordgo.ThreadCreate) {
+	roleID := getQuarantineRoleID(t.GuildID)
+	if roleID == "" || t.ParentID == "" {
+		return
+	}
+
+	parent, err := s.State.Channel(t.ParentID)
+	if err != nil {
+		if parent, err = s.Channel(t.ParentID); err != nil {
+			return
+		}
+	}
+
+	if hasQuarantineOverwrite(parent, roleID) {
+		return
+	}
+
+	if err := denyQuarantineView(s, parent.ID, roleID); err != nil {
+		fmt.Printf("Error setting permissions for thread parent %s: %v\n", parent.ID, err)
+	}
+}
+
+func handleQuarantineReady(s *discordgo.Session, r *discordgo.Ready) {
+	reconcileOnce.Do(func() {
+		go runQuarantineReconciler(s)
+	})
+}
+
+func runQuarantineReconciler(s *discordgo.Session) {
+	ticker := time.NewTicker(quarantineReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		reconcileQuarantineOverwrites(s)
+		<-ticker.C
+	}
+}
+
+// reconcileQuarantineOverwrites restores the deny overwrite on every channel
+// of every guild with a quarantine role configured.
+func reconcileQuarantineOverwrites(s *discordgo.Session) {
+	rows, err := db.Query(`
+        SELECT guild_id, quarantine_role_id
+        FROM antinuke_config
+        WHERE quarantine_role_id IS NOT NULL AND quarantine_role_id != ''`)
+	if err != nil {
+		fmt.Printf("Error loading quarantine roles: %v\n", err)
+		return
+	}
+
+	type guildRole struct {
+		guildID, roleID string
+	}
+	var guilds []guildRole
+	for rows.Next() {
+		var g guildRole
+		if err := rows.Scan(&g.guildID, &g.roleID); err != nil {
+			continue
+		}
+		guilds = append(guilds, g)
+	}
+	rows.Close()
+
+	for _, g := range guilds {
+		guild, err := s.State.Guild(g.guildID)
+		if err != nil {
+			continue
+		}
+
+		fixed := 0
+		for _, channel := range guild.Channels {
+			if hasQuarantineOverwrite(channel, g.roleID) {
+				continue
+			}
+			if err := denyQuarantineView(s, channel.ID, g.roleID); err != nil {
+				fmt.Printf("Error restoring quarantine overwrite on %s: %v\n", channel.ID, err)
+				continue
+			}
+			fixed++
+		}
+
+		if fixed > 0 {
+			fmt.Printf("Restored quarantine overwrite on %d channel(s) in guild %s\n", fixed, g.guildID)
+		}
+	}
+}
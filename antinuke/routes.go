@@ -0,0 +1,62 @@
+package antinuke
+
+import (
+	"time"
+
+	"aware/router"
+)
+
+// RegisterRoutes registers every antinuke and whitelist command, slash
+// command, component and modal with r.
+func RegisterRoutes(r *router.Router) {
+	owner := []router.Middleware{router.GuildOnly(), router.RequireOwner()}
+	commandMiddleware := append(owner, router.Cooldown(3*time.Second))
+
+	r.Command(&router.Command{
+		Name:        "antinuke setup",
+		Description: "Open the Anti-Nuke setup panel",
+		Handler:     setupCommand,
+		Middleware:  commandMiddleware,
+	})
+	r.Command(&router.Command{
+		Name:        "antinuke doctor",
+		Description: "Check the Anti-Nuke setup for problems",
+		Handler:     doctorCommand,
+		Middleware:  commandMiddleware,
+	})
+	r.Command(&router.Command{
+		Name:        "whitelist",
+		Description: "Manage users exempt from Anti-Nuke protection",
+		Usage:       "[add|remove|list] [@user]",
+		Handler:     whitelistCommand,
+		Middleware:  commandMiddleware,
+	})
+
+	r.Slash("antinuke", slashCommand, owner...)
+	r.Slash("whitelist", slashCommand, owner...)
+
+	// Setup panel
+	r.Component(setupButton, router.Interaction(handleStartSetupButton), owner...)
+	r.Component(deleteButton, router.Interaction(handleDeleteSetup), owner...)
+	r.Component(configButton, router.Interaction(handleViewConfig), owner...)
+	r.Component(punishButton, router.Interaction(handlePunishmentOptions), owner...)
+	r.Component(limitsButton, router.Interaction(handleLimitsSetup), owner...)
+	r.Component(kickButton, router.Interaction(handlePunishmentSetup), owner...)
+	r.Component(banButton, router.Interaction(handlePunishmentSetup), owner...)
+	r.Component(quarantineButton, router.Interaction(handlePunishmentSetup), owner...)
+	r.Component(doctorRepairButton, router.Interaction(handleDoctorRepair), owner...)
+	r.Component(enableModeButton, router.Interaction(handleModeSetup), owner...)
+	r.Component(monitorModeButton, router.Interaction(handleModeSetup), owner...)
+	r.Component(disableModeButton, router.Interaction(handleModeSetup), owner...)
+	r.Modal("limits_modal", router.Interaction(handleLimitsModal), owner...)
+
+	// Whitelist menu
+	r.Component(whitelistAddButton, router.Interaction(handleWhitelistAdd), owner...)
+	r.Component(whitelistRemoveButton, router.Interaction(handleWhitelistRemove), owner...)
+	r.Component(whitelistListButton, router.Interaction(handleWhitelistList), owner...)
+	r.Component(whitelistUserSelect, router.Interaction(handleWhitelistSelect), owner...)
+	r.Component(whitelistUserRemove, router.Interaction(handleWhitelistSelect), owner...)
+	r.Component(whitelistConfirmAdd, router.Interaction(handleConfirmAdd), owner...)
+	r.Component(whitelistConfirmRemove, router.Interaction(handleConfirmRemove), owner...)
+	r.Component("cancel_whitelist", router.Interaction(handleCancelWhitelist), owner...)
+}
@@ -0,0 +1,524 @@
+package antinuke
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	// repairMutex serialises repairs so that a burst of delete events for the
+	// same guild doesn't recreate a resource twice.
+	repairMutex sync.Mutex
+
+	botRoleSnapshots = make(map[string]botRoleSnapshot)
+	botRoleMutex     sync.Mutex
+)
+
+// guildResources mirrors the resource columns of antinuke_config.
+// Webhook columns hold the full webhook URL once setup has finished.
+type guildResources struct {
+	LogsChannelID    string
+	ModLogsChannelID string
+	QuarantineRoleID string
+	WebhookURL       string
+	ModWebhookURL    string
+}
+
+type botRoleSnapshot struct {
+	RoleID      string
+	Permissions int64
+	RolesAbove  int
+}
+
+func getGuildResources(guildID string) (*guildResources, error) {
+	var logs, modLogs, role, webhook, modWebhook sql.NullString
+	err := db.QueryRow(`
+        SELECT logs_channel_id, mod_logs_channel_id, quarantine_role_id, webhook_id, mod_webhook_id
+        FROM antinuke_config
+        WHERE guild_id = ?`, guildID).Scan(&logs, &modLogs, &role, &webhook, &modWebhook)
+	if err != nil {
+		return nil, err
+	}
+
+	return &guildResources{
+		LogsChannelID:    logs.String,
+		ModLogsChannelID: modLogs.String,
+		QuarantineRoleID: role.String,
+		WebhookURL:       webhook.String,
+		ModWebhookURL:    modWebhook.String,
+	}, nil
+}
+
+func webhookURL(w *discordgo.Webhook) string {
+	return fmt.Sprintf("https://discord.com/api/webhooks/%s/%s", w.ID, w.Token)
+}
+
+func parseWebhookURL(url string) (string, string, bool) {
+	parts := strings.Split(url, "/")
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], true
+}
+
+// webhookAlive reports whether the webhook behind url still exists.
+func webhookAlive(s *discordgo.Session, url string) bool {
+	id, token, ok := parseWebhookURL(url)
+	if !ok {
+		return false
+	}
+	_, err := s.WebhookWithToken(id, token)
+	return err == nil
+}
+
+// createLogChannel creates a text channel hidden from @everyone.
+func createLogChannel(s *discordgo.Session, guildID, name string) (*discordgo.Channel, error) {
+	channel, err := s.GuildChannelCreate(guildID, name, discordgo.ChannelTypeGuildText)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := hideFromEveryone(s, guildID, channel); err != nil {
+		s.ChannelDelete(channel.ID)
+		return nil, err
+	}
+	return channel, nil
+}
+
+// hideFromEveryone denies View Channel to @everyone, keeping the rest of
+// its existing overwrite.
+func hideFromEveryone(s *discordgo.Session, guildID string, channel *discordgo.Channel) error {
+	var allow, deny int64
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.ID == guildID {
+			allow, deny = overwrite.Allow, overwrite.Deny
+		}
+	}
+
+	return s.ChannelPermissionSet(
+		channel.ID,
+		guildID,
+		discordgo.PermissionOverwriteTypeRole,
+		allow&^discordgo.PermissionViewChannel,
+		deny|discordgo.PermissionViewChannel,
+	)
+}
+
+func createQuarantineRole(s *discordgo.Session, guildID string) (*discordgo.Role, error) {
+	var permissions int64 = 0
+	color := 0x36393f
+	return s.GuildRoleCreate(guildID, &discordgo.RoleParams{
+		Name:        quarantineRole,
+		Permissions: &permissions,
+		Color:       &color,
+	})
+}
+
+// applyQuarantineOverwrites denies View Channel to the quarantine role in
+// every channel of the guild.
+func applyQuarantineOverwrites(s *discordgo.Session, guildID, roleID string) {
+	channels, err := s.GuildChannels(guildID)
+	if err != nil {
+		fmt.Printf("Error fetching channels for guild %s: %v\n", guildID, err)
+		return
+	}
+
+	for _, channel := range channels {
+		err = denyQuarantineView(s, channel.ID, roleID)
+		if err != nil {
+			fmt.Printf("Error setting permissions for channel %s: %v\n", channel.ID, err)
+		}
+	}
+}
+
+// restoreLogChannel recreates the antinuke or mod log channel together with
+// its webhook and points the config row at the new resources.
+func restoreLogChannel(s *discordgo.Session, guildID string, mod bool) error {
+	name, hookName := "antinuke-logs", "Anti-Nuke Logs"
+	channelColumn, webhookColumn := "logs_channel_id", "webhook_id"
+	if mod {
+		name, hookName = "mod-logs", "Mod Logs"
+		channelColumn, webhookColumn = "mod_logs_channel_id", "mod_webhook_id"
+	}
+
+	channel, err := createLogChannel(s, guildID, name)
+	if err != nil {
+		return fmt.Errorf("failed to recreate %s channel: %v", name, err)
+	}
+
+	webhook, err := s.WebhookCreate(channel.ID, hookName, "")
+	if err != nil {
+		s.ChannelDelete(channel.ID)
+		return fmt.Errorf("failed to recreate %s webhook: %v", name, err)
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`
+        UPDATE antinuke_config
+        SET %s = ?, %s = ?
+        WHERE guild_id = ?`, channelColumn, webhookColumn),
+		channel.ID, webhookURL(webhook), guildID,
+	)
+	return err
+}
+
+// restoreWebhook recreates a deleted log webhook in its existing channel.
+func restoreWebhook(s *discordgo.Session, guildID, channelID string, mod bool) error {
+	hookName, column := "Anti-Nuke Logs", "webhook_id"
+	if mod {
+		hookName, column = "Mod Logs", "mod_webhook_id"
+	}
+
+	webhook, err := s.WebhookCreate(channelID, hookName, "")
+	if err != nil {
+		return fmt.Errorf("failed to recreate webhook in %s: %v", channelID, err)
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`
+        UPDATE antinuke_config
+        SET %s = ?
+        WHERE guild_id = ?`, column),
+		webhookURL(webhook), guildID,
+	)
+	return err
+}
+
+func restoreQuarantineRole(s *discordgo.Session, guildID string) error {
+	role, err := createQuarantineRole(s, guildID)
+	if err != nil {
+		return fmt.Errorf("failed to recreate quarantine role: %v", err)
+	}
+
+	applyQuarantineOverwrites(s, guildID, role.ID)
+
+	_, err = db.Exec(`
+        UPDATE antinuke_config
+        SET quarantine_role_id = ?
+        WHERE guild_id = ?`,
+		role.ID, guildID,
+	)
+	return err
+}
+
+// isTrustedActor reports whether userID may modify the bot's setup without
+// being punished: the bot itself, the guild owner and whitelisted users.
+func isTrustedActor(s *discordgo.Session, guildID, userID string) bool {
+	if userID == s.State.User.ID {
+		return true
+	}
+	if guild, err := s.State.Guild(guildID); err == nil && guild.OwnerID == userID {
+		return true
+	}
+	return isWhitelisted(guildID, userID)
+}
+
+// punishTampering punishes the actor behind a setup modification. Repairs
+// must run first so the quarantine role and log webhooks exist again;
+// restoreErr is the outcome of the repair. In monitor mode nothing is
+// repaired, so no rollback is recorded and the detection is only logged.
+func punishTampering(s *discordgo.Session, guildID string, action discordgo.AuditLogAction, what string, restoreErr error) {
+	userID, err := getAuditLogUser(s, guildID, action)
+	if err != nil {
+		fmt.Printf("Error getting audit log user: %v\n", err)
+		return
+	}
+
+	monitor := getProtectionMode(guildID) == modeMonitor
+	if !monitor {
+		recordRollback(guildID, userID, "Setup Tampering", what, restoreErr)
+	}
+
+	if isTrustedActor(s, guildID, userID) {
+		if !monitor {
+			what += " (trusted user, restored)"
+		}
+		sendLogs(s, guildID, userID, "Setup Modified", what)
+		return
+	}
+
+	reason := fmt.Sprintf("Tampering With Anti-Nuke Setup: %s", what)
+	respondToDetection(s, guildID, userID, "Setup Tampering", reason)
+}
+
+func handleSetupChannelDelete(s *discordgo.Session, e *discordgo.ChannelDelete) {
+	if !protectionEnabled(e.GuildID) {
+		return
+	}
+
+	res, err := getGuildResources(e.GuildID)
+	if err != nil {
+		return
+	}
+
+	var mod bool
+	switch e.ID {
+	case res.LogsChannelID:
+		mod = false
+	case res.ModLogsChannelID:
+		mod = true
+	default:
+		return
+	}
+
+	if getProtectionMode(e.GuildID) != modeMonitor {
+		repairMutex.Lock()
+		err = restoreLogChannel(s, e.GuildID, mod)
+		repairMutex.Unlock()
+		if err != nil {
+			fmt.Printf("Error restoring log channel for guild %s: %v\n", e.GuildID, err)
+		}
+	}
+
+	punishTampering(s, e.GuildID, discordgo.AuditLogActionChannelDelete, fmt.Sprintf("Deleted log channel #%s", e.Name), err)
+}
+
+func handleSetupRoleDelete(s *discordgo.Session, e *discordgo.GuildRoleDelete) {
+	if !protectionEnabled(e.GuildID) {
+		return
+	}
+
+	res, err := getGuildResources(e.GuildID)
+	if err != nil || res.QuarantineRoleID == "" || e.RoleID != res.QuarantineRoleID {
+		return
+	}
+
+	if getProtectionMode(e.GuildID) != modeMonitor {
+		repairMutex.Lock()
+		err = restoreQuarantineRole(s, e.GuildID)
+		repairMutex.Unlock()
+		if err != nil {
+			fmt.Printf("Error restoring quarantine role for guild %s: %v\n", e.GuildID, err)
+		}
+	}
+
+	punishTampering(s, e.GuildID, discordgo.AuditLogActionRoleDelete, "Deleted the quarantine role", err)
+}
+
+func handleSetupWebhooksUpdate(s *discordgo.Session, e *discordgo.WebhooksUpdate) {
+	if !protectionEnabled(e.GuildID) {
+		return
+	}
+
+	// Check the webhook under the lock, so the update caused by another
+	// handler's repair sees the new webhook instead of repairing again
+	repairMutex.Lock()
+	tampered, err := repairWebhook(s, e.GuildID, e.ChannelID)
+	repairMutex.Unlock()
+	if !tampered {
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error restoring webhook for guild %s: %v\n", e.GuildID, err)
+	}
+
+	punishTampering(s, e.GuildID, discordgo.AuditLogActionWebhookDelete, "Deleted a log webhook", err)
+}
+
+// repairWebhook reports whether the log webhook for channelID is gone and,
+// outside monitor mode, recreates it. Callers must hold repairMutex.
+func repairWebhook(s *discordgo.Session, guildID, channelID string) (bool, error) {
+	res, err := getGuildResources(guildID)
+	if err != nil {
+		return false, nil
+	}
+
+	var mod bool
+	var url string
+	switch channelID {
+	case res.LogsChannelID:
+		mod, url = false, res.WebhookURL
+	case res.ModLogsChannelID:
+		mod, url = true, res.ModWebhookURL
+	default:
+		return false, nil
+	}
+
+	if webhookAlive(s, url) {
+		return false, nil
+	}
+	if getProtectionMode(guildID) == modeMonitor {
+		return true, nil
+	}
+	return true, restoreWebhook(s, guildID, channelID, mod)
+}
+
+// botManagedRole returns the integration role Discord created for the bot.
+func botManagedRole(s *discordgo.Session, guildID string) (*discordgo.Role, *discordgo.Guild) {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return nil, nil
+	}
+	member, err := s.State.Member(guildID, s.State.User.ID)
+	if err != nil {
+		return nil, guild
+	}
+
+	for _, role := range guild.Roles {
+		if !role.Managed {
+			continue
+		}
+		for _, roleID := range member.Roles {
+			if roleID == role.ID {
+				return role, guild
+			}
+		}
+	}
+	return nil, guild
+}
+
+func countRolesAbove(guild *discordgo.Guild, role *discordgo.Role) int {
+	count := 0
+	for _, r := range guild.Roles {
+		if r.Position > role.Position {
+			count++
+		}
+	}
+	return count
+}
+
+func snapshotBotRole(s *discordgo.Session, guildID string) {
+	role, guild := botManagedRole(s, guildID)
+	if role == nil {
+		return
+	}
+
+	botRoleMutex.Lock()
+	botRoleSnapshots[guildID] = botRoleSnapshot{
+		RoleID:      role.ID,
+		Permissions: role.Permissions,
+		RolesAbove:  countRolesAbove(guild, role),
+	}
+	botRoleMutex.Unlock()
+}
+
+func handleSetupGuildCreate(s *discordgo.Session, e *discordgo.GuildCreate) {
+	snapshotBotRole(s, e.ID)
+}
+
+// botRoleAuditWindow is how old a role update audit log entry may be and
+// still be attributed to the role update being handled.
+const botRoleAuditWindow = 15 * time.Second
+
+// botRoleUpdateEntry returns the recent role update audit log entry for the
+// bot's role, or nil. Other roles' updates, such as one being moved above
+// the bot, must not be blamed on whoever last edited a role.
+func botRoleUpdateEntry(s *discordgo.Session, guildID, roleID string) *discordgo.AuditLogEntry {
+	auditLog, err := s.GuildAuditLog(guildID, "", "", int(discordgo.AuditLogActionRoleUpdate), 5)
+	if err != nil {
+		fmt.Printf("Error getting audit log for guild %s: %v\n", guildID, err)
+		return nil
+	}
+
+	for _, entry := range auditLog.AuditLogEntries {
+		if entry.TargetID != roleID {
+			continue
+		}
+		created, err := discordgo.SnowflakeTimestamp(entry.ID)
+		if err == nil && time.Since(created) < botRoleAuditWindow {
+			return entry
+		}
+	}
+	return nil
+}
+
+func changesKey(entry *discordgo.AuditLogEntry, key discordgo.AuditLogChangeKey) bool {
+	for _, change := range entry.Changes {
+		if change.Key != nil && *change.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// alertOwner DMs the guild owner about something antinuke can't fix.
+func alertOwner(s *discordgo.Session, guildID, message string) {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return
+	}
+	channel, err := s.UserChannelCreate(guild.OwnerID)
+	if err != nil {
+		fmt.Printf("Error opening DM with owner of guild %s: %v\n", guildID, err)
+		return
+	}
+	if _, err := s.ChannelMessageSend(channel.ID, message); err != nil {
+		fmt.Printf("Error alerting owner of guild %s: %v\n", guildID, err)
+	}
+}
+
+func handleBotRoleUpdate(s *discordgo.Session, e *discordgo.GuildRoleUpdate) {
+	botRoleMutex.Lock()
+	snapshot, ok := botRoleSnapshots[e.GuildID]
+	botRoleMutex.Unlock()
+
+	if !ok {
+		snapshotBotRole(s, e.GuildID)
+		return
+	}
+
+	role, guild := botManagedRole(s, e.GuildID)
+	if role == nil {
+		return
+	}
+
+	lostPermissions := snapshot.Permissions &^ role.Permissions
+	lowered := countRolesAbove(guild, role) > snapshot.RolesAbove
+	if lostPermissions == 0 && !lowered {
+		snapshotBotRole(s, e.GuildID)
+		return
+	}
+
+	// Only changes the audit log attributes to an edit of the bot's own
+	// role count as tampering
+	entry := botRoleUpdateEntry(s, e.GuildID, role.ID)
+	if entry == nil || !changesKey(entry, discordgo.AuditLogChangeKeyPermissions) {
+		lostPermissions = 0
+	}
+	if entry == nil || !changesKey(entry, discordgo.AuditLogChangeKeyPosition) {
+		lowered = false
+	}
+	if lostPermissions == 0 && !lowered {
+		snapshotBotRole(s, e.GuildID)
+		return
+	}
+	userID := entry.UserID
+
+	if isTrustedActor(s, e.GuildID, userID) {
+		snapshotBotRole(s, e.GuildID)
+		return
+	}
+
+	var what string
+	switch {
+	case lostPermissions != 0 && lowered:
+		what = "Lowered the bot's role and removed its permissions"
+	case lowered:
+		what = "Lowered the bot's role"
+	default:
+		what = "Removed permissions from the bot's role"
+	}
+
+	// Nothing is rolled back either way: a member can't edit their own
+	// highest role, so the bot can't restore it
+	snapshotBotRole(s, e.GuildID)
+
+	reason := fmt.Sprintf("Tampering With Anti-Nuke Setup: %s", what)
+	if getProtectionMode(e.GuildID) != modeActive {
+		if protectionEnabled(e.GuildID) {
+			recordIncident(e.GuildID, userID, EventDetection, "Setup Tampering", reason+" (monitor mode)")
+			sendMonitorLog(s, e.GuildID, userID, "Setup Tampering", reason)
+		}
+		return
+	}
+
+	recordIncident(e.GuildID, userID, EventDetection, "Setup Tampering", reason+" (not repairable, the bot can't edit its own role)")
+	applyPunishment(s, e.GuildID, userID, "Setup Tampering", reason)
+	sendLogs(s, e.GuildID, userID, "Setup Tampering", reason)
+	alertOwner(s, e.GuildID, fmt.Sprintf(
+		"**Anti-Nuke alert for %s:** <@%s> %s. The bot can't repair its own role, so please restore its permissions and position.",
+		guild.Name, userID, strings.ToLower(what[:1])+what[1:]))
+}
@@ -6,6 +6,11 @@ import (
 	"strings"
 	"time"
 
+	"aware/events"
+	"aware/logging"
+	"aware/router"
+	"aware/settings"
+
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -19,63 +24,53 @@ const (
 	whitelistConfirmRemove = "whitelist_confirm_remove"
 )
 
-func WhitelistCommand(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if !strings.HasPrefix(m.Content, ",whitelist") {
-		return
-	}
-
-	// Check if user is server owner
-	guild, err := s.Guild(m.GuildID)
-	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, "Error fetching guild information.")
-		return
-	}
-
-	if m.Author.ID != guild.OwnerID {
-		s.ChannelMessageSend(m.ChannelID, "Only the server owner can use this command!")
-		return
-	}
-
-	args := strings.Fields(m.Content)
+func whitelistCommand(ctx *router.Context) error {
+	s, m := ctx.Session, ctx.Message
+	args := ctx.Args
 	
 	// Handle subcommands
-	if len(args) > 1 {
-		switch args[1] {
+	if len(args) > 0 {
+		switch args[0] {
 		case "add":
-			if len(args) > 2 {
+			if len(args) > 1 {
 				// Direct add via mention or ID
-				userID := strings.Trim(args[2], "<@!>")
-				addUserToWhitelist(s, m.GuildID, userID, m.Author.ID)
+				userID := strings.Trim(args[1], "<@!>")
+				if err := addUserToWhitelist(s, m.GuildID, userID, m.Author.ID); err != nil {
+					return err
+				}
 				s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("User <@%s> has been added to the whitelist.", userID))
 			} else {
-				s.ChannelMessageSend(m.ChannelID, "Please specify a user to add: `,whitelist add @user`")
+				s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Please specify a user to add: `%swhitelist add @user`", settings.Prefix(m.GuildID)))
 			}
-			return
+			return nil
 		case "remove":
-			if len(args) > 2 {
+			if len(args) > 1 {
 				// Direct remove via mention or ID
-				userID := strings.Trim(args[2], "<@!>")
-				removeUserFromWhitelist(s, m.GuildID, userID)
+				userID := strings.Trim(args[1], "<@!>")
+				if err := removeUserFromWhitelist(s, m.GuildID, userID, m.Author.ID); err != nil {
+					return err
+				}
 				s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("User <@%s> has been removed from the whitelist.", userID))
 			} else {
-				s.ChannelMessageSend(m.ChannelID, "Please specify a user to remove: `,whitelist remove @user`")
+				s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Please specify a user to remove: `%swhitelist remove @user`", settings.Prefix(m.GuildID)))
 			}
-			return
+			return nil
 		case "list":
 			// List all whitelisted users
-			listWhitelistedUsers(s, m.ChannelID, m.GuildID)
-			return
+			return listWhitelistedUsers(s, m.ChannelID, m.GuildID)
 		}
 	}
 
 	// Main whitelist command - show buttons
-	showWhitelistMenu(s, m.ChannelID, m.GuildID)
+	showWhitelistMenu(s, m.ChannelID, m.GuildID, m.Author.ID)
+	return nil
 }
 
-func showWhitelistMenu(s *discordgo.Session, channelID, guildID string) {
+func showWhitelistMenu(s *discordgo.Session, channelID, guildID, userID string) {
+	prefix := settings.Prefix(guildID)
 	embed := &discordgo.MessageEmbed{
 		Title:       "Anti-Nuke Whitelist Management",
-		Description: "Manage users who are exempt from anti-nuke protection\nWhitelist Manager Commands\n- `,whitelist add`\n- `,whitelist remove`\n- `,whitelist list`",
+		Description: fmt.Sprintf("Manage users who are exempt from anti-nuke protection\nWhitelist Manager Commands\n- `%[1]swhitelist add`\n- `%[1]swhitelist remove`\n- `%[1]swhitelist list`", prefix),
 		Color:       0x3498db,
 		Fields: []*discordgo.MessageEmbedField{
 			{
@@ -115,48 +110,17 @@ func showWhitelistMenu(s *discordgo.Session, channelID, guildID string) {
 		},
 	}
 
-	_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
 		Embeds:     []*discordgo.MessageEmbed{embed},
-		Components: components,
+		Components: router.BindComponents(components, userID, guildID),
 	})
+	router.ExpireMessage(s, msg)
 
 	if err != nil {
 		log.Printf("Error sending whitelist menu: %v\n", err)
 	}
 }
 
-func HandleWhitelistButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
-    if i.Type != discordgo.InteractionMessageComponent {
-        return
-    }
-
-    customID := i.MessageComponentData().CustomID
-    
-    // Handle the case where customID starts with a prefix
-    if strings.HasPrefix(customID, whitelistConfirmAdd) || 
-       strings.HasPrefix(customID, whitelistConfirmRemove) {
-        // These are handled by their specific functions
-        if strings.HasPrefix(customID, whitelistConfirmAdd) {
-            handleConfirmAdd(s, i)
-        } else if strings.HasPrefix(customID, whitelistConfirmRemove) {
-            handleConfirmRemove(s, i)
-        }
-        return
-    }
-
-    // Handle exact matches
-    switch customID {
-    case whitelistAddButton:
-        handleWhitelistAdd(s, i)
-    case whitelistRemoveButton:
-        handleWhitelistRemove(s, i)
-    case whitelistListButton:
-        handleWhitelistList(s, i)
-    case "cancel_whitelist":
-        handleCancelWhitelist(s, i)
-    }
-}
-
 func handleCancelWhitelist(s *discordgo.Session, i *discordgo.InteractionCreate) {
     // First, acknowledge the interaction immediately
     err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -179,11 +143,7 @@ func handleCancelWhitelist(s *discordgo.Session, i *discordgo.InteractionCreate)
     }
 }
 
-func HandleWhitelistSelect(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if i.Type != discordgo.InteractionMessageComponent {
-		return
-	}
-
+func handleWhitelistSelect(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	data := i.MessageComponentData()
 	
 	if data.CustomID == whitelistUserSelect {
@@ -198,12 +158,12 @@ func HandleWhitelistSelect(s *discordgo.Session, i *discordgo.InteractionCreate)
 							discordgo.Button{
 								Label:    "Confirm",
 								Style:    discordgo.SuccessButton,
-								CustomID: whitelistConfirmAdd + ":" + data.Values[0],
+								CustomID: router.Bind(whitelistConfirmAdd+":"+data.Values[0], i.Member.User.ID, i.GuildID, router.ComponentTTL),
 							},
 							discordgo.Button{
 								Label:    "Cancel",
 								Style:    discordgo.DangerButton,
-								CustomID: "cancel_whitelist",
+								CustomID: router.Bind("cancel_whitelist", i.Member.User.ID, i.GuildID, router.ComponentTTL),
 							},
 						},
 					},
@@ -223,12 +183,12 @@ func HandleWhitelistSelect(s *discordgo.Session, i *discordgo.InteractionCreate)
 							discordgo.Button{
 								Label:    "Confirm",
 								Style:    discordgo.DangerButton,
-								CustomID: whitelistConfirmRemove + ":" + data.Values[0],
+								CustomID: router.Bind(whitelistConfirmRemove+":"+data.Values[0], i.Member.User.ID, i.GuildID, router.ComponentTTL),
 							},
 							discordgo.Button{
 								Label:    "Cancel",
 								Style:    discordgo.SecondaryButton,
-								CustomID: "cancel_whitelist",
+								CustomID: router.Bind("cancel_whitelist", i.Member.User.ID, i.GuildID, router.ComponentTTL),
 							},
 						},
 					},
@@ -240,19 +200,6 @@ func HandleWhitelistSelect(s *discordgo.Session, i *discordgo.InteractionCreate)
 }
 
 func handleWhitelistAdd(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	// Check if user is server owner
-	guild, err := s.Guild(i.GuildID)
-	if err != nil || i.Member.User.ID != guild.OwnerID {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Only the server owner can manage the whitelist.",
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
-		})
-		return
-	}
-
 	// Get guild members for dropdown
 	members, err := s.GuildMembers(i.GuildID, "", 100)
 	if err != nil {
@@ -275,8 +222,10 @@ func handleWhitelistAdd(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		}
 		
 		var count int
-err := db.QueryRow("SELECT COUNT(*) FROM antinuke_whitelist WHERE guild_id = ? AND user_id = ?", 
-    i.GuildID, member.User.ID).Scan(&count)
+err := db.QueryRow(`
+    SELECT COUNT(*) FROM antinuke_whitelist
+    WHERE guild_id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)`,
+    i.GuildID, member.User.ID, time.Now().UTC().Format(time.RFC3339)).Scan(&count)
 
 if err != nil || count > 0 {
     continue
@@ -314,7 +263,7 @@ if err != nil || count > 0 {
 				discordgo.ActionsRow{
 					Components: []discordgo.MessageComponent{
 						discordgo.SelectMenu{
-							CustomID:    whitelistUserSelect,
+							CustomID:    router.Bind(whitelistUserSelect, i.Member.User.ID, i.GuildID, router.ComponentTTL),
 							Placeholder: "Select a user",
 							Options:     options,
 						},
@@ -327,21 +276,11 @@ if err != nil || count > 0 {
 }
 
 func handleWhitelistRemove(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	// Check if user is server owner
-	guild, err := s.Guild(i.GuildID)
-	if err != nil || i.Member.User.ID != guild.OwnerID {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Only the server owner can manage the whitelist.",
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
-		})
-		return
-	}
-
 	// Get whitelisted users
-	rows, err := db.Query("SELECT user_id FROM antinuke_whitelist WHERE guild_id = ?", i.GuildID)
+	rows, err := db.Query(`
+		SELECT user_id FROM antinuke_whitelist
+		WHERE guild_id = ? AND (expires_at IS NULL OR expires_at > ?)`,
+		i.GuildID, time.Now().UTC().Format(time.RFC3339))
 	if err != nil {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -396,7 +335,7 @@ func handleWhitelistRemove(s *discordgo.Session, i *discordgo.InteractionCreate)
 				discordgo.ActionsRow{
 					Components: []discordgo.MessageComponent{
 						discordgo.SelectMenu{
-							CustomID:    whitelistUserRemove,
+							CustomID:    router.Bind(whitelistUserRemove, i.Member.User.ID, i.GuildID, router.ComponentTTL),
 							Placeholder: "Select a user",
 							Options:     options,
 						},
@@ -416,10 +355,13 @@ func handleWhitelistList(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		},
 	})
 	
-	listWhitelistedUsers(s, "", i.GuildID)
+	purgeExpiredWhitelist(i.GuildID)
 	
 	// Create a formatted list of whitelisted users
-	rows, err := db.Query("SELECT user_id, added_by, added_at FROM antinuke_whitelist WHERE guild_id = ?", i.GuildID)
+	rows, err := db.Query(`
+		SELECT user_id, added_by, added_at, COALESCE(expires_at, '') FROM antinuke_whitelist
+		WHERE guild_id = ? AND (expires_at IS NULL OR expires_at > ?)`,
+		i.GuildID, time.Now().UTC().Format(time.RFC3339))
 	if err != nil {
 		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
 			Content: stringPtr("Error fetching whitelisted users."),
@@ -432,13 +374,17 @@ func handleWhitelistList(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	count := 0
 	
 	for rows.Next() {
-		var userID, addedBy, addedAt string
-		if err := rows.Scan(&userID, &addedBy, &addedAt); err != nil {
+		var userID, addedBy, addedAt, expiresAt string
+		if err := rows.Scan(&userID, &addedBy, &addedAt, &expiresAt); err != nil {
 			continue
 		}
 		
 		count++
-		users.WriteString(fmt.Sprintf("%d. <@%s> (Added by <@%s>)\n", count, userID, addedBy))
+		users.WriteString(fmt.Sprintf("%d. <@%s> (Added by <@%s>)", count, userID, addedBy))
+		if expires, err := time.Parse(time.RFC3339, expiresAt); err == nil {
+			users.WriteString(fmt.Sprintf(" expires <t:%d:R>", expires.Unix()))
+		}
+		users.WriteString("\n")
 	}
 
 	if count == 0 {
@@ -536,7 +482,7 @@ func handleConfirmRemove(s *discordgo.Session, i *discordgo.InteractionCreate) {
     userID := parts[1]
     
     // Remove user from whitelist with proper error handling
-    err = removeUserFromWhitelist(s, i.GuildID, userID)
+    err = removeUserFromWhitelist(s, i.GuildID, userID, i.Member.User.ID)
     if err != nil {
         log.Printf("Error removing user from whitelist: %v", err)
         s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
@@ -558,26 +504,70 @@ func handleConfirmRemove(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
 
 func addUserToWhitelist(s *discordgo.Session, guildID, userID, addedByID string) error {
+	return addUserToWhitelistFor(s, guildID, userID, addedByID, 0)
+}
+
+// addUserToWhitelistFor whitelists a user for the given duration, or
+// permanently when duration is 0.
+func addUserToWhitelistFor(s *discordgo.Session, guildID, userID, addedByID string, duration time.Duration) error {
+	var expiresAt interface{}
+	var expires *time.Time
+	if duration > 0 {
+		t := time.Now().UTC().Add(duration)
+		expiresAt, expires = t.Format(time.RFC3339), &t
+	}
+
 	_, err := db.Exec(`
 		INSERT OR REPLACE INTO antinuke_whitelist 
-		(guild_id, user_id, added_by, added_at) 
-		VALUES (?, ?, ?, ?)`,
-		guildID, userID, addedByID, time.Now().Format(time.RFC3339))
-	
-	return err
+		(guild_id, user_id, added_by, added_at, expires_at) 
+		VALUES (?, ?, ?, ?, ?)`,
+		guildID, userID, addedByID, time.Now().Format(time.RFC3339), expiresAt)
+	if err != nil {
+		return err
+	}
+
+	change := fmt.Sprintf("Added <@%s>", userID)
+	if duration > 0 {
+		change = fmt.Sprintf("Added <@%s> for %s", userID, duration)
+	}
+	logging.LogConfigChange(s, guildID, addedByID, "Anti-Nuke whitelist", change)
+	events.Publish(guildID, events.TypeWhitelistAdd, events.WhitelistData{UserID: userID, ByID: addedByID, ExpiresAt: expires})
+	return nil
 }
 
-func removeUserFromWhitelist(s *discordgo.Session, guildID, userID string) error {
+func removeUserFromWhitelist(s *discordgo.Session, guildID, userID, removedByID string) error {
     _, err := db.Exec(`
         DELETE FROM antinuke_whitelist 
         WHERE guild_id = ? AND user_id = ?`,
         guildID, userID)
-    
-    return err
+    if err != nil {
+        return err
+    }
+
+    logging.LogConfigChange(s, guildID, removedByID, "Anti-Nuke whitelist", fmt.Sprintf("Removed <@%s>", userID))
+    events.Publish(guildID, events.TypeWhitelistRemove, events.WhitelistData{UserID: userID, ByID: removedByID})
+    return nil
+}
+
+// purgeExpiredWhitelist deletes the guild's expired whitelist entries.
+// They no longer count either way, this just keeps the table small.
+func purgeExpiredWhitelist(guildID string) {
+    _, err := db.Exec(`
+        DELETE FROM antinuke_whitelist
+        WHERE guild_id = ? AND expires_at IS NOT NULL AND expires_at <= ?`,
+        guildID, time.Now().UTC().Format(time.RFC3339))
+    if err != nil {
+        fmt.Printf("Error purging expired whitelist entries: %v\n", err)
+    }
 }
 
 func listWhitelistedUsers(s *discordgo.Session, channelID, guildID string) error {
-    rows, err := db.Query("SELECT user_id FROM antinuke_whitelist WHERE guild_id = ?", guildID)
+    purgeExpiredWhitelist(guildID)
+
+    rows, err := db.Query(`
+        SELECT user_id FROM antinuke_whitelist
+        WHERE guild_id = ? AND (expires_at IS NULL OR expires_at > ?)`,
+        guildID, time.Now().UTC().Format(time.RFC3339))
     if err != nil {
         return err
     }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"aware/events"
+	"aware/logging"
+	"aware/router"
+	"aware/settings"
+)
+
+func registerCommands(r *router.Router) {
+	r.Command(&router.Command{
+		Name:        "help",
+		Description: "Show this list of commands",
+		Handler: func(ctx *router.Context) error {
+			_, err := ctx.Session.ChannelMessageSendEmbed(ctx.ChannelID, r.HelpEmbed(ctx.GuildID))
+			return err
+		},
+		Middleware: []router.Middleware{router.Cooldown(5 * time.Second)},
+	})
+
+	r.Command(&router.Command{
+		Name:        "ping",
+		Description: "Show API and WebSocket latency",
+		Handler:     pingCommand,
+		Middleware:  []router.Middleware{router.Cooldown(3 * time.Second)},
+	})
+
+	admin := []router.Middleware{router.GuildOnly(), router.RequireAdmin(), router.Cooldown(3 * time.Second)}
+
+	r.Command(&router.Command{
+		Name:        "prefix",
+		Description: "Show the command prefix for this server",
+		Handler:     prefixCommand,
+		Middleware:  []router.Middleware{router.GuildOnly()},
+	})
+	r.Command(&router.Command{
+		Name:        "prefix set",
+		Description: "Change the command prefix for this server",
+		Usage:       "<prefix>",
+		Handler:     prefixSetCommand,
+		Middleware:  admin,
+	})
+	r.Command(&router.Command{
+		Name:        "prefix reset",
+		Description: "Restore the default command prefix",
+		Handler:     prefixResetCommand,
+		Middleware:  admin,
+	})
+}
+
+func prefixCommand(ctx *router.Context) error {
+	_, err := ctx.Session.ChannelMessageSend(ctx.ChannelID,
+		fmt.Sprintf("The prefix for this server is `%s`", settings.Prefix(ctx.GuildID)))
+	return err
+}
+
+func prefixSetCommand(ctx *router.Context) error {
+	if len(ctx.Args) != 1 {
+		return router.Errorf("Usage: `%sprefix set <prefix>`", settings.Prefix(ctx.GuildID))
+	}
+
+	if err := settings.ValidatePrefix(ctx.Args[0]); err != nil {
+		return router.Errorf("%s", err.Error())
+	}
+
+	if err := settings.SetPrefix(ctx.GuildID, ctx.Args[0]); err != nil {
+		return err
+	}
+	logging.LogConfigChange(ctx.Session, ctx.GuildID, ctx.UserID, "Prefix", ctx.Args[0])
+	events.PublishConfigUpdate(ctx.GuildID, ctx.UserID, "Prefix", ctx.Args[0])
+
+	_, err := ctx.Session.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("Prefix set to `%s`", ctx.Args[0]))
+	return err
+}
+
+func prefixResetCommand(ctx *router.Context) error {
+	if err := settings.SetPrefix(ctx.GuildID, ""); err != nil {
+		return err
+	}
+	logging.LogConfigChange(ctx.Session, ctx.GuildID, ctx.UserID, "Prefix", settings.DefaultPrefix)
+	events.PublishConfigUpdate(ctx.GuildID, ctx.UserID, "Prefix", settings.DefaultPrefix)
+
+	_, err := ctx.Session.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("Prefix reset to `%s`", settings.DefaultPrefix))
+	return err
+}
+
+func pingCommand(ctx *router.Context) error {
+	s, m := ctx.Session, ctx.Message
+
+	msgTime := m.Timestamp
+
+	latency := time.Since(msgTime).Milliseconds()
+	wsLatency := s.HeartbeatLatency().Milliseconds()
+
+	pingMessage := fmt.Sprintf("🏓 Pong!\n⏱️ API Latency: %dms\n📡 WebSocket: %dms", latency, wsLatency)
+	_, err := s.ChannelMessageSend(m.ChannelID, pingMessage)
+	return err
+}
@@ -0,0 +1,272 @@
+// Package config loads the bot's settings from the environment, a .env file
+// and an optional TOML or YAML config file.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	defaultDatabasePath    = "./main.db"
+	defaultPort            = "8080"
+	defaultShutdownTimeout = 10 * time.Second
+
+	// MinSecretLength matches the key size gorilla recommends for signing.
+	MinSecretLength = 32
+)
+
+// defaultConfigFiles are looked for when CONFIG_FILE isn't set.
+var defaultConfigFiles = []string{"aware.toml", "aware.yaml", "aware.yml"}
+
+var snowflakePattern = regexp.MustCompile(`^[0-9]{17,20}$`)
+
+// Config is the bot's startup configuration.
+type Config struct {
+	Token           string
+	DatabasePath    string
+	Port            string
+	ShutdownTimeout time.Duration
+	// LogChannelID receives bot guild join/leave logs unless another
+	// destination is stored for the category.
+	LogChannelID string
+	// OwnerIDs are the bot's owners, who can configure bot-wide settings.
+	OwnerIDs []string
+	Intents  discordgo.Intent
+	// ComponentSecret signs bound component IDs so they survive restarts.
+	// Random per run when empty.
+	ComponentSecret string
+	Dashboard       Dashboard
+}
+
+// Dashboard is the dashboard's OAuth and session configuration.
+type Dashboard struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// SessionSecret signs session cookies. One is generated and stored in
+	// the database when empty.
+	SessionSecret string
+}
+
+// setting maps an environment variable to its config file key.
+type setting struct {
+	env string
+	key string
+}
+
+var (
+	settingToken           = setting{"DISCORD_TOKEN", "token"}
+	settingDatabasePath    = setting{"DATABASE_PATH", "database_path"}
+	settingPort            = setting{"PORT", "port"}
+	settingShutdownTimeout = setting{"SHUTDOWN_TIMEOUT", "shutdown_timeout"}
+	settingLogChannelID    = setting{"LOG_CHANNEL_ID", "log_channel_id"}
+	settingOwnerIDs        = setting{"OWNER_IDS", "owner_ids"}
+	settingIntents         = setting{"INTENTS", "intents"}
+	settingComponentSecret = setting{"COMPONENT_SECRET", "component_secret"}
+	settingClientID        = setting{"DISCORD_APP_ID", "dashboard.client_id"}
+	settingClientSecret    = setting{"DISCORD_SECRET", "dashboard.client_secret"}
+	settingRedirectURL     = setting{"REDIRECT_URL", "dashboard.redirect_url"}
+	settingSessionSecret   = setting{"SESSION_SECRET", "dashboard.session_secret"}
+)
+
+func (s setting) String() string {
+	return fmt.Sprintf("%s (%s)", s.env, s.key)
+}
+
+// source looks settings up in the environment first and the config file
+// second.
+type source map[string]string
+
+func (src source) get(s setting) string {
+	if value, ok := os.LookupEnv(s.env); ok {
+		return strings.TrimSpace(value)
+	}
+	return src[s.key]
+}
+
+// Load reads .env into the environment, without overriding variables that
+// are already set, then builds the config from the environment and the
+// config file named by CONFIG_FILE (aware.toml, aware.yaml or aware.yml by
+// default, if one exists).
+func Load() (*Config, error) {
+	if err := loadDotEnv(".env"); err != nil {
+		return nil, fmt.Errorf("reading .env: %w", err)
+	}
+
+	file, err := readConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	return parse(source(file))
+}
+
+// readConfigFile reads the file named by CONFIG_FILE, or the first of the
+// default config files that exists. It's parsed as YAML for .yaml and .yml
+// files and as TOML otherwise.
+func readConfigFile() (map[string]string, error) {
+	if path, ok := os.LookupEnv("CONFIG_FILE"); ok {
+		return readFile(path)
+	}
+
+	for _, path := range defaultConfigFiles {
+		values, err := readFile(path)
+		if !errors.Is(err, os.ErrNotExist) {
+			return values, err
+		}
+	}
+	return map[string]string{}, nil
+}
+
+func readFile(path string) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return readYAML(path)
+	default:
+		return readTOML(path)
+	}
+}
+
+// parse builds and validates the config, reporting every invalid setting
+// at once.
+func parse(src source) (*Config, error) {
+	var problems []string
+	invalid := func(s setting, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("%s %s", s, fmt.Sprintf(format, args...)))
+	}
+	required := func(s setting) string {
+		value := src.get(s)
+		if value == "" {
+			invalid(s, "is required")
+		}
+		return value
+	}
+
+	cfg := &Config{
+		Token:           required(settingToken),
+		DatabasePath:    src.get(settingDatabasePath),
+		Port:            src.get(settingPort),
+		ShutdownTimeout: defaultShutdownTimeout,
+		LogChannelID:    src.get(settingLogChannelID),
+		Intents:         discordgo.IntentsAll,
+		ComponentSecret: src.get(settingComponentSecret),
+		Dashboard: Dashboard{
+			ClientID:      required(settingClientID),
+			ClientSecret:  required(settingClientSecret),
+			RedirectURL:   required(settingRedirectURL),
+			SessionSecret: src.get(settingSessionSecret),
+		},
+	}
+
+	cfg.Token = strings.TrimPrefix(cfg.Token, "Bot ")
+	if cfg.DatabasePath == "" {
+		cfg.DatabasePath = defaultDatabasePath
+	}
+
+	if cfg.Port == "" {
+		cfg.Port = defaultPort
+	} else if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		invalid(settingPort, "must be a port number, got %q", cfg.Port)
+	}
+
+	if value := src.get(settingShutdownTimeout); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			invalid(settingShutdownTimeout, "must be a duration such as 15s, got %q", value)
+		} else {
+			cfg.ShutdownTimeout = timeout
+		}
+	}
+
+	if cfg.LogChannelID != "" && !snowflakePattern.MatchString(cfg.LogChannelID) {
+		invalid(settingLogChannelID, "must be a channel ID, got %q", cfg.LogChannelID)
+	}
+
+	for _, id := range splitList(src.get(settingOwnerIDs)) {
+		if !snowflakePattern.MatchString(id) {
+			invalid(settingOwnerIDs, "must be a list of user IDs, got %q", id)
+			continue
+		}
+		cfg.OwnerIDs = append(cfg.OwnerIDs, id)
+	}
+
+	if value := src.get(settingIntents); value != "" {
+		intents, err := parseIntents(value)
+		if err != nil {
+			invalid(settingIntents, "%v", err)
+		} else {
+			cfg.Intents = intents
+		}
+	}
+
+	if cfg.ComponentSecret != "" && len(cfg.ComponentSecret) < MinSecretLength {
+		invalid(settingComponentSecret, "must be at least %d characters", MinSecretLength)
+	}
+	if cfg.Dashboard.SessionSecret != "" && len(cfg.Dashboard.SessionSecret) < MinSecretLength {
+		invalid(settingSessionSecret, "must be at least %d characters", MinSecretLength)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return cfg, nil
+}
+
+// splitList splits a comma separated list, ignoring empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+var intentNames = map[string]discordgo.Intent{
+	"all":                      discordgo.IntentsAll,
+	"unprivileged":             discordgo.IntentsAllWithoutPrivileged,
+	"guilds":                   discordgo.IntentsGuilds,
+	"guild_members":            discordgo.IntentsGuildMembers,
+	"guild_moderation":         discordgo.IntentsGuildBans,
+	"guild_emojis":             discordgo.IntentsGuildEmojis,
+	"guild_integrations":       discordgo.IntentsGuildIntegrations,
+	"guild_webhooks":           discordgo.IntentsGuildWebhooks,
+	"guild_invites":            discordgo.IntentsGuildInvites,
+	"guild_voice_states":       discordgo.IntentsGuildVoiceStates,
+	"guild_presences":          discordgo.IntentsGuildPresences,
+	"guild_messages":           discordgo.IntentsGuildMessages,
+	"guild_message_reactions":  discordgo.IntentsGuildMessageReactions,
+	"guild_message_typing":     discordgo.IntentsGuildMessageTyping,
+	"direct_messages":          discordgo.IntentsDirectMessages,
+	"direct_message_reactions": discordgo.IntentsDirectMessageReactions,
+	"direct_message_typing":    discordgo.IntentsDirectMessageTyping,
+	"message_content":          discordgo.IntentsMessageContent,
+	"guild_scheduled_events":   discordgo.IntentsGuildScheduledEvents,
+}
+
+// parseIntents accepts a raw intents bitfield or a comma separated list of
+// intent names such as "guilds, guild_members".
+func parseIntents(value string) (discordgo.Intent, error) {
+	if n, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return discordgo.Intent(n), nil
+	}
+
+	var intents discordgo.Intent
+	for _, name := range splitList(value) {
+		intent, ok := intentNames[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("has unknown intent %q", name)
+		}
+		intents |= intent
+	}
+	return intents, nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// clearEnv unsets every setting's environment variable for the test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, s := range []setting{
+		settingToken, settingDatabasePath, settingPort, settingShutdownTimeout,
+		settingLogChannelID, settingOwnerIDs, settingIntents, settingComponentSecret,
+		settingClientID, settingClientSecret, settingRedirectURL, settingSessionSecret,
+	} {
+		t.Setenv(s.env, "")
+		os.Unsetenv(s.env)
+	}
+}
+
+func validSource() source {
+	return source{
+		"token":                   "Bot abc",
+		"dashboard.client_id":     "id",
+		"dashboard.client_secret": "secret",
+		"dashboard.redirect_url":  "http://localhost:8080/callback",
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := parse(validSource())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "abc" {
+		t.Errorf("Token = %q, want the Bot prefix stripped", cfg.Token)
+	}
+	if cfg.DatabasePath != defaultDatabasePath || cfg.Port != defaultPort ||
+		cfg.ShutdownTimeout != defaultShutdownTimeout || cfg.Intents != discordgo.IntentsAll {
+		t.Errorf("parse() = %+v, want the defaults", cfg)
+	}
+}
+
+func TestParseEnvOverridesFile(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("PORT", "9000")
+
+	src := validSource()
+	src["port"] = "8081"
+	src["owner_ids"] = "123456789012345678,223456789012345678"
+	src["intents"] = "guilds, Guild_Members"
+	src["shutdown_timeout"] = "30s"
+
+	cfg, err := parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9000" {
+		t.Errorf("Port = %q, want the environment's 9000", cfg.Port)
+	}
+	if want := []string{"123456789012345678", "223456789012345678"}; !reflect.DeepEqual(cfg.OwnerIDs, want) {
+		t.Errorf("OwnerIDs = %v, want %v", cfg.OwnerIDs, want)
+	}
+	if want := discordgo.IntentsGuilds | discordgo.IntentsGuildMembers; cfg.Intents != want {
+		t.Errorf("Intents = %d, want %d", cfg.Intents, want)
+	}
+	if cfg.ShutdownTimeout != 30*time.Second {
+		t.Errorf("ShutdownTimeout = %v, want 30s", cfg.ShutdownTimeout)
+	}
+}
+
+func TestParseReportsEveryProblem(t *testing.T) {
+	clearEnv(t)
+
+	_, err := parse(source{
+		"port":                     "http",
+		"shutdown_timeout":         "-1s",
+		"log_channel_id":           "general",
+		"owner_ids":                "me",
+		"intents":                  "guilds,everything",
+		"component_secret":         "short",
+		"dashboard.session_secret": "short",
+	})
+	if err == nil {
+		t.Fatal("parse() succeeded, want an error")
+	}
+	for _, s := range []setting{
+		settingToken, settingClientID, settingClientSecret, settingRedirectURL,
+		settingPort, settingShutdownTimeout, settingLogChannelID, settingOwnerIDs,
+		settingIntents, settingComponentSecret, settingSessionSecret,
+	} {
+		if !strings.Contains(err.Error(), s.String()) {
+			t.Errorf("error doesn't mention %s:\n%v", s, err)
+		}
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeFile(t, "bot.yml", "dashboard:\n  client_id: id\n"))
+	got, err := readConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["dashboard.client_id"] != "id" {
+		t.Errorf("readConfigFile() = %v, want the YAML file's settings", got)
+	}
+
+	t.Setenv("CONFIG_FILE", writeFile(t, "missing.toml", "")+".gone")
+	if _, err := readConfigFile(); err == nil {
+		t.Error("readConfigFile() ignored a missing CONFIG_FILE")
+	}
+}
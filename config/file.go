@@ -0,0 +1,238 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadDotEnv sets the KEY=value pairs in path as environment variables,
+// keeping variables that are already set. A missing file is not an error.
+func loadDotEnv(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("%s:%d: expected KEY=value", path, n)
+		}
+
+		value, err := unquote(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+		}
+	}
+	return scanner.Err()
+}
+
+// readTOML reads the flat subset of TOML the config file uses: [tables],
+// key = value pairs with string, number or boolean values, and single-line
+// string arrays. Keys in a table are returned as "table.key", and arrays
+// as comma separated lists.
+func readTOML(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	table := ""
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: unterminated table header", path, n)
+			}
+			table = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		if table != "" {
+			key = table + "." + key
+		}
+
+		value, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// readYAML reads the flat subset of YAML the config file uses: key: value
+// pairs, one level of nested mappings, and lists written inline as
+// [a, b] or as "- item" lines. Nested keys are returned as "parent.key",
+// and lists as comma separated lists.
+func readYAML(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	parent, listKey := "", ""
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		text := stripComment(scanner.Text())
+		line := strings.TrimSpace(text)
+		if line == "" || line == "---" {
+			continue
+		}
+		indented := text[0] == ' ' || text[0] == '\t'
+
+		if item, ok := strings.CutPrefix(line, "- "); ok {
+			if listKey == "" {
+				return nil, fmt.Errorf("%s:%d: list item without a key", path, n)
+			}
+			value, err := unquote(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			if values[listKey] != "" {
+				value = values[listKey] + "," + value
+			}
+			values[listKey] = value
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected key: value", path, n)
+		}
+		if !indented {
+			parent = ""
+		} else if parent == "" {
+			return nil, fmt.Errorf("%s:%d: unexpected indentation", path, n)
+		}
+
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			// Either a nested mapping or a block list follows
+			if indented {
+				listKey = joinKey(parent, key)
+			} else {
+				parent, listKey = key, key
+			}
+			continue
+		}
+		listKey = ""
+
+		value, err := parseYAMLValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if indented {
+			key = joinKey(parent, key)
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+func joinKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func parseYAMLValue(raw string) (string, error) {
+	if strings.HasPrefix(raw, "[") {
+		return parseTOMLValue(raw)
+	}
+	return unquote(raw)
+}
+
+func parseTOMLValue(raw string) (string, error) {
+	if strings.HasPrefix(raw, "[") {
+		if !strings.HasSuffix(raw, "]") {
+			return "", fmt.Errorf("arrays must be on one line")
+		}
+		var items []string
+		for _, item := range splitList(raw[1 : len(raw)-1]) {
+			value, err := unquote(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, value)
+		}
+		return strings.Join(items, ","), nil
+	}
+
+	if strings.HasPrefix(raw, `"`) || strings.HasPrefix(raw, "'") {
+		return unquote(raw)
+	}
+	if raw == "true" || raw == "false" {
+		return raw, nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(raw, "_", ""), 64); err == nil {
+		return strings.ReplaceAll(raw, "_", ""), nil
+	}
+	return "", fmt.Errorf("unsupported value %q, strings must be quoted", raw)
+}
+
+// unquote removes double quotes, handling escapes, or single quotes,
+// taken literally. Unquoted values are returned as is.
+func unquote(value string) (string, error) {
+	switch {
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		return strconv.Unquote(value)
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1], nil
+	case strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'"):
+		return "", fmt.Errorf("unterminated string %s", value)
+	}
+	return value, nil
+}
+
+// stripComment removes a # comment that isn't inside a string.
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadTOML(t *testing.T) {
+	path := writeFile(t, "aware.toml", `
+# bot settings
+token = "abc # not a comment"
+port = 8_080
+owner_ids = ["1", '2']  # trailing comment
+debug = true
+
+[dashboard]
+client_id = "id"
+redirect_url = 'http://localhost:8080/callback'
+`)
+
+	got, err := readTOML(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"token":                  "abc # not a comment",
+		"port":                   "8080",
+		"owner_ids":              "1,2",
+		"debug":                  "true",
+		"dashboard.client_id":    "id",
+		"dashboard.redirect_url": "http://localhost:8080/callback",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readTOML() = %v, want %v", got, want)
+	}
+}
+
+func TestReadTOMLErrors(t *testing.T) {
+	tests := map[string]string{
+		"unquoted string":    "token = abc",
+		"missing value":      "token",
+		"unterminated table": "[dashboard",
+		"unterminated array": `owner_ids = ["1"`,
+		"unterminated quote": `token = "abc`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readTOML(writeFile(t, "aware.toml", content)); err == nil {
+				t.Errorf("readTOML(%q) succeeded, want an error", content)
+			}
+		})
+	}
+}
+
+func TestReadYAML(t *testing.T) {
+	path := writeFile(t, "aware.yaml", `---
+# bot settings
+token: "abc # not a comment"
+port: 8080
+owner_ids:
+  - "1"
+  - 2
+intents: [guilds, 'guild_members']
+
+dashboard:
+  client_id: id # trailing comment
+  redirect_url: http://localhost:8080/callback
+database_path: ./main.db
+`)
+
+	got, err := readYAML(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"token":                  "abc # not a comment",
+		"port":                   "8080",
+		"owner_ids":              "1,2",
+		"intents":                "guilds,guild_members",
+		"dashboard.client_id":    "id",
+		"dashboard.redirect_url": "http://localhost:8080/callback",
+		"database_path":          "./main.db",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readYAML() = %v, want %v", got, want)
+	}
+}
+
+func TestReadYAMLErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing colon":      "token",
+		"orphan list item":   "- 1",
+		"orphan indentation": "  token: abc",
+		"unterminated quote": `token: "abc`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readYAML(writeFile(t, "aware.yaml", content)); err == nil {
+				t.Errorf("readYAML(%q) succeeded, want an error", content)
+			}
+		})
+	}
+}
+
+func TestReadFileByExtension(t *testing.T) {
+	// Valid YAML but not valid TOML, so only the YAML parser accepts it
+	const content = "token: abc\n"
+	for _, name := range []string{"aware.yaml", "aware.yml", "AWARE.YML"} {
+		got, err := readFile(writeFile(t, name, content))
+		if err != nil {
+			t.Errorf("readFile(%s): %v", name, err)
+		} else if got["token"] != "abc" {
+			t.Errorf("readFile(%s)[token] = %q, want abc", name, got["token"])
+		}
+	}
+	if _, err := readFile(writeFile(t, "aware.toml", content)); err == nil {
+		t.Error("readFile(aware.toml) parsed YAML as TOML")
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	t.Setenv("AWARE_TEST_SET", "from env")
+	path := writeFile(t, ".env", `
+# comment
+export AWARE_TEST_EXPORTED=exported
+AWARE_TEST_QUOTED="a \"quoted\" value"
+AWARE_TEST_SET=from file
+`)
+	for _, key := range []string{"AWARE_TEST_EXPORTED", "AWARE_TEST_QUOTED"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	if err := loadDotEnv(path); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"AWARE_TEST_EXPORTED": "exported",
+		"AWARE_TEST_QUOTED":   `a "quoted" value`,
+		"AWARE_TEST_SET":      "from env",
+	}
+	for key, value := range want {
+		if got := os.Getenv(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+
+	if err := loadDotEnv(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("loadDotEnv(missing file) = %v, want nil", err)
+	}
+	if err := loadDotEnv(writeFile(t, ".env", "not a pair")); err == nil {
+		t.Error("loadDotEnv(malformed) succeeded, want an error")
+	}
+}
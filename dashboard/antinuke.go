@@ -0,0 +1,232 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"aware/antinuke"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/mux"
+)
+
+// configUpdate is a partial antinuke config change. Nil fields are left
+// unchanged.
+type configUpdate struct {
+	ActionsPerMinute *int    `json:"actions_per_minute"`
+	ActionsPerHour   *int    `json:"actions_per_hour"`
+	PunishmentType   *string `json:"punishment_type"`
+	Mode             *string `json:"mode"`
+	LogsChannelID    *string `json:"logs_channel_id"`
+	ModLogsChannelID *string `json:"mod_logs_channel_id"`
+}
+
+// applyConfigUpdate applies the fields of update that differ from the
+// current config. Every field is validated before anything is saved, so a
+// rejected update changes nothing.
+func applyConfigUpdate(guildID, userID string, update configUpdate) error {
+	current, err := antinuke.GetConfig(guildID)
+	if err != nil {
+		return err
+	}
+
+	apm, aph := current.ActionsPerMinute, current.ActionsPerHour
+	if update.ActionsPerMinute != nil {
+		apm = *update.ActionsPerMinute
+	}
+	if update.ActionsPerHour != nil {
+		aph = *update.ActionsPerHour
+	}
+	setLimits := apm != current.ActionsPerMinute || aph != current.ActionsPerHour
+	setPunishment := update.PunishmentType != nil && *update.PunishmentType != current.PunishmentType
+	setMode := update.Mode != nil && *update.Mode != current.Mode
+	setLogs := update.LogsChannelID != nil && *update.LogsChannelID != current.LogsChannelID
+	setModLogs := update.ModLogsChannelID != nil && *update.ModLogsChannelID != current.ModLogsChannelID
+
+	if setLimits {
+		if err := antinuke.ValidateLimits(apm, aph); err != nil {
+			return &antinuke.ValidationError{Message: err.Error()}
+		}
+	}
+	if setPunishment {
+		if err := antinuke.ValidatePunishment(*update.PunishmentType); err != nil {
+			return err
+		}
+	}
+	if setMode {
+		if err := antinuke.ValidateMode(*update.Mode); err != nil {
+			return err
+		}
+	}
+	if setLogs {
+		if err := antinuke.ValidateLogChannel(bot, guildID, *update.LogsChannelID); err != nil {
+			return err
+		}
+	}
+	if setModLogs {
+		if err := antinuke.ValidateLogChannel(bot, guildID, *update.ModLogsChannelID); err != nil {
+			return err
+		}
+	}
+
+	if setLimits {
+		if err := antinuke.SetLimits(bot, guildID, userID, apm, aph); err != nil {
+			return err
+		}
+	}
+	if setPunishment {
+		if err := antinuke.SetPunishment(bot, guildID, userID, *update.PunishmentType); err != nil {
+			return err
+		}
+	}
+	if setMode {
+		if err := antinuke.SetMode(bot, guildID, userID, *update.Mode); err != nil {
+			return err
+		}
+	}
+	if setLogs {
+		if err := antinuke.SetLogChannel(bot, guildID, userID, *update.LogsChannelID, false); err != nil {
+			return err
+		}
+	}
+	if setModLogs {
+		if err := antinuke.SetLogChannel(bot, guildID, userID, *update.ModLogsChannelID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateStatus maps an update error to an HTTP status.
+func updateStatus(err error) int {
+	var validation *antinuke.ValidationError
+	if errors.As(err, &validation) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func textChannels(guildID string) []*discordgo.Channel {
+	guild, err := bot.State.Guild(guildID)
+	if err != nil {
+		return nil
+	}
+
+	var channels []*discordgo.Channel
+	for _, channel := range guild.Channels {
+		if channel.Type == discordgo.ChannelTypeGuildText {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Slice(channels, func(a, b int) bool {
+		return channels[a].Position < channels[b].Position
+	})
+	return channels
+}
+
+func renderAntinuke(w http.ResponseWriter, r *http.Request, status int, message string) {
+	guildID := mux.Vars(r)["guildID"]
+	user, _ := currentUser(r)
+	guild, _ := bot.State.Guild(guildID)
+
+	config, err := antinuke.GetConfig(guildID)
+	if err != nil {
+		http.Error(w, "Failed to load config", http.StatusInternalServerError)
+		return
+	}
+
+	renderStatus(w, r, status, "antinuke", map[string]interface{}{
+		"User":        user,
+		"Guild":       guild,
+		"Config":      config,
+		"Channels":    textChannels(guildID),
+		"Punishments": antinuke.PunishmentTypes,
+		"Modes":       antinuke.Modes,
+		"Message":     message,
+		"Failed":      status != http.StatusOK,
+	})
+}
+
+func handleAntinukePage(w http.ResponseWriter, r *http.Request) {
+	message := ""
+	if r.URL.Query().Get("saved") != "" {
+		message = "Settings saved"
+	}
+	renderAntinuke(w, r, http.StatusOK, message)
+}
+
+func handleAntinukeForm(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderAntinuke(w, r, http.StatusBadRequest, "Invalid form")
+		return
+	}
+
+	apm, errMinute := strconv.Atoi(r.PostForm.Get("actions_per_minute"))
+	aph, errHour := strconv.Atoi(r.PostForm.Get("actions_per_hour"))
+	if errMinute != nil || errHour != nil {
+		renderAntinuke(w, r, http.StatusBadRequest, "Limits must be numbers")
+		return
+	}
+
+	punishment := r.PostForm.Get("punishment_type")
+	mode := r.PostForm.Get("mode")
+	logs := r.PostForm.Get("logs_channel_id")
+	modLogs := r.PostForm.Get("mod_logs_channel_id")
+	update := configUpdate{
+		ActionsPerMinute: &apm,
+		ActionsPerHour:   &aph,
+		PunishmentType:   &punishment,
+		Mode:             &mode,
+	}
+	if logs != "" {
+		update.LogsChannelID = &logs
+	}
+	if modLogs != "" {
+		update.ModLogsChannelID = &modLogs
+	}
+
+	if err := applyConfigUpdate(mux.Vars(r)["guildID"], actorID(r), update); err != nil {
+		renderAntinuke(w, r, updateStatus(err), err.Error())
+		return
+	}
+
+	http.Redirect(w, r, r.URL.Path+"?saved=1", http.StatusSeeOther)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func handleAntinukeGet(w http.ResponseWriter, r *http.Request) {
+	config, err := antinuke.GetConfig(mux.Vars(r)["guildID"])
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load config")
+		return
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+func handleAntinukePatch(w http.ResponseWriter, r *http.Request) {
+	var update configUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&update); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	guildID := mux.Vars(r)["guildID"]
+	if err := applyConfigUpdate(guildID, actorID(r), update); err != nil {
+		writeJSONError(w, updateStatus(err), err.Error())
+		return
+	}
+
+	handleAntinukeGet(w, r)
+}
@@ -0,0 +1,263 @@
+package dashboard
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aware/events"
+	"aware/logging"
+
+	"github.com/gorilla/mux"
+)
+
+// API key scopes. Write keys can also read.
+const (
+	scopeRead  = "read"
+	scopeWrite = "write"
+)
+
+const (
+	apiKeyPrefix     = "aw_"
+	maxKeysPerGuild  = 10
+	maxKeyNameLength = 64
+)
+
+var errTooManyKeys = errors.New("this server already has the maximum number of API keys")
+
+// apiKey is a stored API key. Only a hash of the key itself is kept.
+type apiKey struct {
+	ID         int64
+	GuildID    string
+	Name       string
+	Scope      string
+	Hint       string
+	CreatedBy  string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+func createAPIKeyTable() error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS api_keys (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            guild_id TEXT NOT NULL,
+            name TEXT NOT NULL,
+            scope TEXT NOT NULL,
+            hash TEXT NOT NULL UNIQUE,
+            hint TEXT NOT NULL,
+            created_by TEXT NOT NULL,
+            created_at TEXT NOT NULL,
+            last_used_at TEXT,
+            revoked_at TEXT
+        )
+    `)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_api_keys_guild ON api_keys (guild_id)")
+	return err
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// createAPIKey stores a new key for the guild and returns it. The key can't
+// be recovered later.
+func createAPIKey(guildID, name, scope, createdBy string) (string, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM api_keys WHERE guild_id = ? AND revoked_at IS NULL", guildID).Scan(&count)
+	if err != nil {
+		return "", err
+	}
+	if count >= maxKeysPerGuild {
+		return "", errTooManyKeys
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	key := apiKeyPrefix + token
+
+	_, err = db.Exec(`
+        INSERT INTO api_keys (guild_id, name, scope, hash, hint, created_by, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		guildID, name, scope, hashAPIKey(key), key[len(key)-4:], createdBy, formatSessionTime(time.Now()))
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// revokeAPIKey revokes one of the guild's keys, reporting whether it existed.
+func revokeAPIKey(guildID string, id int64) (bool, error) {
+	res, err := db.Exec("UPDATE api_keys SET revoked_at = ? WHERE guild_id = ? AND id = ? AND revoked_at IS NULL",
+		formatSessionTime(time.Now()), guildID, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*apiKey, error) {
+	var key apiKey
+	var createdAt string
+	var lastUsed sql.NullString
+	if err := row.Scan(&key.ID, &key.GuildID, &key.Name, &key.Scope, &key.Hint, &key.CreatedBy, &createdAt, &lastUsed); err != nil {
+		return nil, err
+	}
+	key.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	if t, err := time.Parse(time.RFC3339, lastUsed.String); err == nil {
+		key.LastUsedAt = &t
+	}
+	return &key, nil
+}
+
+// listAPIKeys returns the guild's active keys, newest first.
+func listAPIKeys(guildID string) ([]*apiKey, error) {
+	rows, err := db.Query(`
+        SELECT id, guild_id, name, scope, hint, created_by, created_at, last_used_at
+        FROM api_keys
+        WHERE guild_id = ? AND revoked_at IS NULL
+        ORDER BY id DESC`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*apiKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// lookupAPIKey returns the active key matching key, or nil.
+func lookupAPIKey(key string) (*apiKey, error) {
+	found, err := scanAPIKey(db.QueryRow(`
+        SELECT id, guild_id, name, scope, hint, created_by, created_at, last_used_at
+        FROM api_keys
+        WHERE hash = ? AND revoked_at IS NULL`, hashAPIKey(key)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return found, err
+}
+
+func touchAPIKey(id int64) {
+	if _, err := db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", formatSessionTime(time.Now()), id); err != nil {
+		log.Printf("Error updating API key usage: %v", err)
+	}
+}
+
+func renderAPIKeys(w http.ResponseWriter, r *http.Request, status int, message, newKey string) {
+	guildID := mux.Vars(r)["guildID"]
+	user, _ := currentUser(r)
+	guild, _ := bot.State.Guild(guildID)
+
+	keys, err := listAPIKeys(guildID)
+	if err != nil {
+		http.Error(w, "Failed to load API keys", http.StatusInternalServerError)
+		return
+	}
+
+	creators := make(map[string]userInfo)
+	for _, key := range keys {
+		if _, ok := creators[key.CreatedBy]; !ok {
+			creators[key.CreatedBy] = resolveUser(guildID, key.CreatedBy)
+		}
+	}
+
+	if newKey != "" {
+		// The new key is only ever shown on this page
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	renderStatus(w, r, status, "apikeys", map[string]interface{}{
+		"User":     user,
+		"Guild":    guild,
+		"Keys":     keys,
+		"Creators": creators,
+		"NewKey":   newKey,
+		"Message":  message,
+		"Failed":   status >= http.StatusBadRequest,
+	})
+}
+
+func handleAPIKeysPage(w http.ResponseWriter, r *http.Request) {
+	message := ""
+	if r.URL.Query().Get("revoked") != "" {
+		message = "API key revoked"
+	}
+	renderAPIKeys(w, r, http.StatusOK, message, "")
+}
+
+func handleAPIKeyCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderAPIKeys(w, r, http.StatusBadRequest, "Invalid form", "")
+		return
+	}
+
+	name := strings.TrimSpace(r.PostForm.Get("name"))
+	if name == "" || len(name) > maxKeyNameLength {
+		renderAPIKeys(w, r, http.StatusBadRequest, fmt.Sprintf("Name must be 1-%d characters", maxKeyNameLength), "")
+		return
+	}
+	scope := r.PostForm.Get("scope")
+	if scope != scopeRead && scope != scopeWrite {
+		renderAPIKeys(w, r, http.StatusBadRequest, "Scope must be read or write", "")
+		return
+	}
+
+	guildID := mux.Vars(r)["guildID"]
+	user, _ := currentUser(r)
+	key, err := createAPIKey(guildID, name, scope, user.ID)
+	if err == errTooManyKeys {
+		renderAPIKeys(w, r, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating API key: %v", err)
+		renderAPIKeys(w, r, http.StatusInternalServerError, "Failed to create API key", "")
+		return
+	}
+
+	logging.LogConfigChange(bot, guildID, user.ID, "API keys", fmt.Sprintf("Created %s key %q", scope, name))
+	events.PublishConfigUpdate(guildID, user.ID, "API keys", fmt.Sprintf("Created %s key %q", scope, name))
+	renderAPIKeys(w, r, http.StatusCreated, "API key created. Copy it now, it won't be shown again.", key)
+}
+
+func handleAPIKeyRevoke(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["keyID"], 10, 64)
+
+	ok, err := revokeAPIKey(vars["guildID"], id)
+	if err != nil {
+		log.Printf("Error revoking API key: %v", err)
+		renderAPIKeys(w, r, http.StatusInternalServerError, "Failed to revoke API key", "")
+		return
+	}
+	if !ok {
+		renderAPIKeys(w, r, http.StatusNotFound, "API key not found", "")
+		return
+	}
+
+	user, _ := currentUser(r)
+	logging.LogConfigChange(bot, vars["guildID"], user.ID, "API keys", fmt.Sprintf("Revoked key #%d", id))
+	events.PublishConfigUpdate(vars["guildID"], user.ID, "API keys", fmt.Sprintf("Revoked key #%d", id))
+	http.Redirect(w, r, "/dashboard/"+vars["guildID"]+"/api-keys?revoked=1", http.StatusSeeOther)
+}
@@ -0,0 +1,177 @@
+package dashboard
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aware/antinuke"
+
+	"github.com/gorilla/mux"
+)
+
+// apiRateLimit is how many requests a key may make per minute. Keys can
+// burst up to the full limit.
+const apiRateLimit = 60
+
+type contextKey int
+
+const apiKeyContextKey contextKey = iota
+
+// bucket is a token bucket refilled at apiRateLimit tokens per minute.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var (
+	limiterMutex sync.Mutex
+	limiters     = make(map[int64]*bucket)
+)
+
+// allowRequest takes a token from the key's bucket, returning the tokens
+// left and, when empty, how long until the next one.
+func allowRequest(keyID int64) (int, time.Duration, bool) {
+	limiterMutex.Lock()
+	defer limiterMutex.Unlock()
+
+	now := time.Now()
+	b, ok := limiters[keyID]
+	if !ok {
+		b = &bucket{tokens: apiRateLimit, last: now}
+		limiters[keyID] = b
+	}
+
+	perSecond := float64(apiRateLimit) / 60
+	b.tokens = math.Min(apiRateLimit, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+		return 0, wait, false
+	}
+	b.tokens--
+	return int(b.tokens), 0, true
+}
+
+// requireAPIKey authenticates a v1 request by its bearer key, which must
+// belong to the {guildID} guild and have the scope the method needs.
+func requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !strings.HasPrefix(token, apiKeyPrefix) {
+			writeJSONError(w, http.StatusUnauthorized, "Missing API key")
+			return
+		}
+
+		key, err := lookupAPIKey(token)
+		if err != nil {
+			log.Printf("Error looking up API key: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to check API key")
+			return
+		}
+		if key == nil {
+			writeJSONError(w, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+		if key.GuildID != mux.Vars(r)["guildID"] {
+			writeJSONError(w, http.StatusForbidden, "API key is not valid for this server")
+			return
+		}
+
+		// Keys act as the owner who created them, so they stop working
+		// once that user no longer owns the server
+		guild, err := bot.State.Guild(key.GuildID)
+		if err != nil {
+			writeJSONError(w, http.StatusForbidden, "The bot is not in this server")
+			return
+		}
+		if guild.OwnerID != key.CreatedBy {
+			if _, err := revokeAPIKey(key.GuildID, key.ID); err != nil {
+				log.Printf("Error revoking API key: %v", err)
+			}
+			writeJSONError(w, http.StatusUnauthorized, "API key was revoked because server ownership changed")
+			return
+		}
+		if !safeMethod(r.Method) && key.Scope != scopeWrite {
+			writeJSONError(w, http.StatusForbidden, "API key is read-only")
+			return
+		}
+
+		remaining, wait, ok := allowRequest(key.ID)
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(apiRateLimit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeJSONError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+
+		touchAPIKey(key.ID)
+		next(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)))
+	}
+}
+
+// actorID returns who is making the request: the logged in user, or for
+// API key requests the owner who created the key.
+func actorID(r *http.Request) string {
+	if key, ok := r.Context().Value(apiKeyContextKey).(*apiKey); ok {
+		return key.CreatedBy
+	}
+	user, _ := currentUser(r)
+	return user.ID
+}
+
+// registerAPIv1 mounts the API key authenticated REST API.
+func registerAPIv1(r *mux.Router) {
+	v1 := r.PathPrefix("/api/v1/guilds/{guildID:[0-9]+}").Subrouter()
+
+	v1.HandleFunc("/config", requireAPIKey(handleAntinukeGet)).Methods(http.MethodGet)
+	v1.HandleFunc("/config", requireAPIKey(handleAntinukePatch)).Methods(http.MethodPatch)
+	v1.HandleFunc("/whitelist", requireAPIKey(handleWhitelistGet)).Methods(http.MethodGet)
+	v1.HandleFunc("/whitelist", requireAPIKey(handleWhitelistPost)).Methods(http.MethodPost)
+	v1.HandleFunc("/whitelist/{userID:[0-9]+}", requireAPIKey(handleWhitelistDelete)).Methods(http.MethodDelete)
+	v1.HandleFunc("/incidents", requireAPIKey(handleIncidentsGet)).Methods(http.MethodGet)
+	v1.HandleFunc("/incidents/{incidentID:[0-9]+}", requireAPIKey(handleIncidentGet)).Methods(http.MethodGet)
+	v1.HandleFunc("/quarantine", requireAPIKey(handleQuarantineGet)).Methods(http.MethodGet)
+	v1.HandleFunc("/quarantine/{userID:[0-9]+}", requireAPIKey(handleQuarantinePut)).Methods(http.MethodPut)
+	v1.HandleFunc("/quarantine/{userID:[0-9]+}", requireAPIKey(handleQuarantineDelete)).Methods(http.MethodDelete)
+}
+
+func handleQuarantineGet(w http.ResponseWriter, r *http.Request) {
+	guildID := mux.Vars(r)["guildID"]
+	members, err := antinuke.QuarantinedMembers(bot, guildID)
+	if err != nil {
+		writeJSONError(w, updateStatus(err), err.Error())
+		return
+	}
+
+	users := make([]userInfo, 0, len(members))
+	for _, id := range members {
+		users = append(users, resolveUser(guildID, id))
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+func handleQuarantinePut(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := antinuke.Quarantine(bot, vars["guildID"], vars["userID"], actorID(r)); err != nil {
+		writeJSONError(w, updateStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleQuarantineDelete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := antinuke.Release(bot, vars["guildID"], vars["userID"], actorID(r)); err != nil {
+		writeJSONError(w, updateStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
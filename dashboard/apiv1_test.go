@@ -0,0 +1,195 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// callAPI sends a request through requireAPIKey to a handler that returns
+// 204, with guildID as the route's {guildID}.
+func callAPI(method, guildID, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api/v1/guilds/"+guildID+"/config", nil)
+	if key != "" {
+		r.Header.Set("Authorization", "Bearer "+key)
+	}
+	r = mux.SetURLVars(r, map[string]string{"guildID": guildID})
+
+	w := httptest.NewRecorder()
+	requireAPIKey(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})(w, r)
+	return w
+}
+
+func mustCreateAPIKey(t *testing.T, guildID, scope string) string {
+	t.Helper()
+	key, err := createAPIKey(guildID, "test", scope, testOwnerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAllowRequest(t *testing.T) {
+	setupTestDB(t)
+
+	for i := apiRateLimit - 1; i >= 0; i-- {
+		remaining, _, ok := allowRequest(1)
+		if !ok || remaining != i {
+			t.Fatalf("allowRequest() = %d, %v during the burst, want %d, true", remaining, ok, i)
+		}
+	}
+
+	_, wait, ok := allowRequest(1)
+	if ok {
+		t.Fatal("allowRequest() allowed a request past the burst")
+	}
+	if wait <= 0 || wait > time.Minute/apiRateLimit {
+		t.Errorf("wait = %v, want up to %v", wait, time.Minute/apiRateLimit)
+	}
+
+	if _, _, ok := allowRequest(2); !ok {
+		t.Error("another key's bucket was emptied too")
+	}
+
+	// Half a minute refills half the bucket
+	limiterMutex.Lock()
+	limiters[1].last = limiters[1].last.Add(-30 * time.Second)
+	limiterMutex.Unlock()
+
+	remaining, _, ok := allowRequest(1)
+	if !ok || remaining < apiRateLimit/2-2 || remaining > apiRateLimit/2 {
+		t.Errorf("allowRequest() after 30s = %d, %v, want about %d left", remaining, ok, apiRateLimit/2)
+	}
+
+	// The bucket never holds more than the limit
+	limiterMutex.Lock()
+	limiters[1].last = limiters[1].last.Add(-time.Hour)
+	limiterMutex.Unlock()
+
+	if remaining, _, _ := allowRequest(1); remaining != apiRateLimit-1 {
+		t.Errorf("allowRequest() after an hour = %d left, want %d", remaining, apiRateLimit-1)
+	}
+}
+
+func TestRequireAPIKeyRateLimit(t *testing.T) {
+	setupTestDB(t)
+	setupTestGuild(t, testOwnerID)
+	key := mustCreateAPIKey(t, testGuildID, scopeRead)
+
+	for i := 0; i < apiRateLimit; i++ {
+		if w := callAPI(http.MethodGet, testGuildID, key); w.Code != http.StatusNoContent {
+			t.Fatalf("request %d = %d, want %d", i+1, w.Code, http.StatusNoContent)
+		}
+	}
+
+	w := callAPI(http.MethodGet, testGuildID, key)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request past the limit = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 0", got)
+	}
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retry < 1 {
+		t.Errorf("Retry-After = %q, want a positive number of seconds", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestRequireAPIKeyScopes(t *testing.T) {
+	setupTestDB(t)
+	setupTestGuild(t, testOwnerID)
+	read := mustCreateAPIKey(t, testGuildID, scopeRead)
+	write := mustCreateAPIKey(t, testGuildID, scopeWrite)
+
+	tests := []struct {
+		method string
+		key    string
+		want   int
+	}{
+		{http.MethodGet, read, http.StatusNoContent},
+		{http.MethodPatch, read, http.StatusForbidden},
+		{http.MethodPost, read, http.StatusForbidden},
+		{http.MethodPut, read, http.StatusForbidden},
+		{http.MethodDelete, read, http.StatusForbidden},
+		{http.MethodGet, write, http.StatusNoContent},
+		{http.MethodPatch, write, http.StatusNoContent},
+		{http.MethodPost, write, http.StatusNoContent},
+		{http.MethodPut, write, http.StatusNoContent},
+		{http.MethodDelete, write, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		scope := scopeRead
+		if tt.key == write {
+			scope = scopeWrite
+		}
+		t.Run(scope+" "+tt.method, func(t *testing.T) {
+			if w := callAPI(tt.method, testGuildID, tt.key); w.Code != tt.want {
+				t.Errorf("%s with a %s key = %d, want %d", tt.method, scope, w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireAPIKeyRejects(t *testing.T) {
+	setupTestDB(t)
+	setupTestGuild(t, testOwnerID)
+
+	revoked := mustCreateAPIKey(t, testGuildID, scopeWrite)
+	keys, err := listAPIKeys(testGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := revokeAPIKey(testGuildID, keys[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	otherGuild := mustCreateAPIKey(t, "100000000000000002", scopeWrite)
+
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{"missing key", "", http.StatusUnauthorized},
+		{"not an API key", "abc", http.StatusUnauthorized},
+		{"unknown key", apiKeyPrefix + "unknown", http.StatusUnauthorized},
+		{"revoked key", revoked, http.StatusUnauthorized},
+		{"other guild's key", otherGuild, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := callAPI(http.MethodGet, testGuildID, tt.key); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireAPIKeyOwnershipChange(t *testing.T) {
+	setupTestDB(t)
+	setupTestGuild(t, testOwnerID)
+	key := mustCreateAPIKey(t, testGuildID, scopeWrite)
+
+	if w := callAPI(http.MethodGet, testGuildID, key); w.Code != http.StatusNoContent {
+		t.Fatalf("status before the transfer = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	setupTestGuild(t, "200000000000000002")
+	if w := callAPI(http.MethodGet, testGuildID, key); w.Code != http.StatusUnauthorized {
+		t.Errorf("status after the transfer = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if found, err := lookupAPIKey(key); err != nil || found != nil {
+		t.Errorf("lookupAPIKey() = %v, %v, want the key revoked", found, err)
+	}
+
+	// Transferring the server back doesn't bring the key back
+	setupTestGuild(t, testOwnerID)
+	if w := callAPI(http.MethodGet, testGuildID, key); w.Code != http.StatusUnauthorized {
+		t.Errorf("status after transferring back = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
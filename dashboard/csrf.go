@@ -0,0 +1,103 @@
+package dashboard
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+const (
+	// stateTTL is how long a login may take between leaving for Discord and
+	// coming back to the callback.
+	stateTTL = 10 * time.Minute
+
+	csrfField  = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// tokensEqual compares secrets in constant time.
+func tokensEqual(a, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// consumeState removes the session's OAuth state and reports whether it
+// matches state and hasn't expired. The state is cleared even on failure,
+// so each one can only be tried once.
+func consumeState(r *http.Request, w http.ResponseWriter, session *sessions.Session, state string) bool {
+	expected, _ := session.Values["state"].(string)
+	expires, _ := session.Values["state_expires"].(int64)
+
+	delete(session.Values, "state")
+	delete(session.Values, "state_expires")
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error clearing OAuth state: %v", err)
+		return false
+	}
+
+	return tokensEqual(state, expected) && time.Now().Unix() < expires
+}
+
+// csrfToken returns the session's CSRF token, creating it for sessions
+// that predate CSRF protection.
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	session, _ := store.Get(r, "discord-auth")
+	if token, ok := session.Values[csrfField].(string); ok {
+		return token
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		log.Printf("Error generating CSRF token: %v", err)
+		return ""
+	}
+	session.Values[csrfField] = token
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error saving CSRF token: %v", err)
+		return ""
+	}
+	return token
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// csrfProtect rejects state-changing requests that don't carry the
+// session's CSRF token, as a csrf_token form field or X-CSRF-Token header.
+func csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The v1 API authenticates with API keys, never cookies, so it can't
+		// be forged cross-site
+		if safeMethod(r.Method) || strings.HasPrefix(r.URL.Path, "/api/v1/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, _ := store.Get(r, "discord-auth")
+		expected, _ := session.Values[csrfField].(string)
+
+		token := r.Header.Get(csrfHeader)
+		if token == "" {
+			token = r.PostFormValue(csrfField)
+		}
+
+		if !tokensEqual(token, expected) {
+			forbidden(w, r, "Invalid or missing CSRF token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleCSRFToken gives API clients the token to send in X-CSRF-Token.
+func handleCSRFToken(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"csrf_token": csrfToken(w, r)})
+}
@@ -0,0 +1,153 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// saveTestSession stores a session holding values and returns its cookie.
+func saveTestSession(t *testing.T, values map[interface{}]interface{}) *http.Cookie {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, err := store.Get(r, "discord-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range values {
+		session.Values[k] = v
+	}
+
+	w := httptest.NewRecorder()
+	if err := session.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+	return sessionCookie(t, w)
+}
+
+func sessionCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "discord-auth" {
+			return c
+		}
+	}
+	t.Fatal("no session cookie was set")
+	return nil
+}
+
+func TestCSRFProtect(t *testing.T) {
+	setupTestDB(t)
+	const token = "csrf-token"
+	cookie := saveTestSession(t, map[interface{}]interface{}{csrfField: token})
+
+	handler := csrfProtect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		form   string
+		cookie bool
+		want   int
+	}{
+		{"GET without token", http.MethodGet, "/dashboard", "", "", true, http.StatusNoContent},
+		{"POST without token", http.MethodPost, "/dashboard/1/whitelist", "", "", true, http.StatusForbidden},
+		{"PATCH without token", http.MethodPatch, "/api/guilds/1/antinuke", "", "", true, http.StatusForbidden},
+		{"DELETE without token", http.MethodDelete, "/api/guilds/1/whitelist/2", "", "", true, http.StatusForbidden},
+		{"POST with wrong token", http.MethodPost, "/dashboard/1/whitelist", "", "wrong", true, http.StatusForbidden},
+		{"POST without session", http.MethodPost, "/logout", "", token, false, http.StatusForbidden},
+		{"POST with form token", http.MethodPost, "/dashboard/1/whitelist", "", token, true, http.StatusNoContent},
+		{"PATCH with header token", http.MethodPatch, "/api/guilds/1/antinuke", token, "", true, http.StatusNoContent},
+		{"DELETE with header token", http.MethodDelete, "/api/guilds/1/whitelist/2", token, "", true, http.StatusNoContent},
+		{"v1 POST without token", http.MethodPost, "/api/v1/guilds/1/whitelist", "", "", false, http.StatusNoContent},
+		{"v1 DELETE without token", http.MethodDelete, "/api/v1/guilds/1/whitelist/2", "", "", false, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			if tt.form != "" {
+				form.Set(csrfField, tt.form)
+			}
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.header != "" {
+				r.Header.Set(csrfHeader, tt.header)
+			}
+			if tt.cookie {
+				r.AddCookie(cookie)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsumeState(t *testing.T) {
+	setupTestDB(t)
+
+	// consume checks state against the stored session and returns the
+	// session's cookie afterwards.
+	consume := func(cookie *http.Cookie, state string) (bool, *http.Cookie) {
+		r := httptest.NewRequest(http.MethodGet, "/callback", nil)
+		r.AddCookie(cookie)
+		session, err := store.Get(r, "discord-auth")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		ok := consumeState(r, w, session, state)
+		return ok, sessionCookie(t, w)
+	}
+	withState := func(state string, expires time.Time) *http.Cookie {
+		return saveTestSession(t, map[interface{}]interface{}{
+			"state":         state,
+			"state_expires": expires.Unix(),
+		})
+	}
+
+	t.Run("valid then replayed", func(t *testing.T) {
+		cookie := withState("state", time.Now().Add(stateTTL))
+		ok, cookie := consume(cookie, "state")
+		if !ok {
+			t.Fatal("consumeState() rejected a valid state")
+		}
+		if ok, _ := consume(cookie, "state"); ok {
+			t.Error("consumeState() accepted a replayed state")
+		}
+	})
+
+	t.Run("wrong state", func(t *testing.T) {
+		cookie := withState("state", time.Now().Add(stateTTL))
+		if ok, cookie := consume(cookie, "other"); ok {
+			t.Error("consumeState() accepted the wrong state")
+		} else if ok, _ := consume(cookie, "state"); ok {
+			t.Error("consumeState() accepted a state after a failed attempt")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		cookie := withState("state", time.Now().Add(-time.Second))
+		if ok, _ := consume(cookie, "state"); ok {
+			t.Error("consumeState() accepted an expired state")
+		}
+	})
+
+	t.Run("no state", func(t *testing.T) {
+		cookie := saveTestSession(t, nil)
+		if ok, _ := consume(cookie, ""); ok {
+			t.Error("consumeState() accepted an empty state")
+		}
+	})
+}
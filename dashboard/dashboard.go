@@ -1,22 +1,30 @@
 package dashboard
 
 import (
+	"database/sql"
+	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
-	"os"
-
+	"strings"
 	"time"
 
+	"aware/config"
+
+	"github.com/bwmarrin/discordgo"
 	"github.com/gorilla/mux"
-	"github.com/gorilla/sessions"
 	"github.com/ravener/discord-oauth2"
 	"golang.org/x/oauth2"
 )
 
 var (
-	store        = sessions.NewCookieStore([]byte(os.Getenv("SESSION_SECRET")))
+	store        *sqliteStore
 	oauthConfig  *oauth2.Config
+
+	// db and bot are shared with the running bot.
+	db  *sql.DB
+	bot *discordgo.Session
 )
 
 type User struct {
@@ -26,27 +34,91 @@ type User struct {
 	Avatar        string `json:"avatar"`
 }
 
-func Initialize(r *mux.Router) {
+// Initialize mounts the dashboard routes on r, using the bot's database and
+// Discord session. It fails if sessions can't be set up.
+func Initialize(r *mux.Router, database *sql.DB, session *discordgo.Session, cfg config.Dashboard) error {
+	db = database
+	bot = session
+
+	secret, err := loadSessionSecret(cfg.SessionSecret)
+	if err != nil {
+		return fmt.Errorf("loading session secret: %w", err)
+	}
+	secure := strings.HasPrefix(cfg.RedirectURL, "https://")
+	if store, err = newSQLiteStore(secret, secure); err != nil {
+		return fmt.Errorf("creating session store: %w", err)
+	}
+	if err := createAPIKeyTable(); err != nil {
+		return fmt.Errorf("creating API key table: %w", err)
+	}
+
+	r.Use(csrfProtect)
+
 	oauthConfig = &oauth2.Config{
-		RedirectURL:  os.Getenv("REDIRECT_URL"),
-		ClientID:     os.Getenv("DISCORD_APP_ID"),
-		ClientSecret: os.Getenv("DISCORD_SECRET"),
-		Scopes:       []string{discord.ScopeIdentify},
+		RedirectURL:  cfg.RedirectURL,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Scopes:       []string{discord.ScopeIdentify, discord.ScopeGuilds},
 		Endpoint:     discord.Endpoint,
 	}
 
 	r.HandleFunc("/login", handleLogin)
 	r.HandleFunc("/callback", handleCallback)
-	r.HandleFunc("/logout", handleLogout)
+	r.HandleFunc("/logout", handleLogout).Methods(http.MethodPost)
+	r.HandleFunc("/logout/all", requireAuth(handleLogoutAll)).Methods(http.MethodPost)
 	r.HandleFunc("/dashboard", requireAuth(handleDashboard))
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}", requireGuildAccess(handleGuild))
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/antinuke", requireGuildOwner(handleAntinukePage)).Methods(http.MethodGet)
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/antinuke", requireGuildOwner(handleAntinukeForm)).Methods(http.MethodPost)
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/whitelist", requireGuildOwner(handleWhitelistPage)).Methods(http.MethodGet)
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/whitelist", requireGuildOwner(handleWhitelistAddForm)).Methods(http.MethodPost)
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/whitelist/{userID:[0-9]+}/remove", requireGuildOwner(handleWhitelistRemoveForm)).Methods(http.MethodPost)
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/incidents", requireGuildOwner(handleIncidentsPage)).Methods(http.MethodGet)
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/incidents/{incidentID:[0-9]+}", requireGuildOwner(handleIncidentPage)).Methods(http.MethodGet)
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/incidents/{incidentID:[0-9]+}/export", requireGuildOwner(handleIncidentExport)).Methods(http.MethodGet)
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/api-keys", requireGuildOwner(handleAPIKeysPage)).Methods(http.MethodGet)
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/api-keys", requireGuildOwner(handleAPIKeyCreate)).Methods(http.MethodPost)
+	r.HandleFunc("/dashboard/{guildID:[0-9]+}/api-keys/{keyID:[0-9]+}/revoke", requireGuildOwner(handleAPIKeyRevoke)).Methods(http.MethodPost)
+
+	r.HandleFunc("/api/csrf", requireAuth(handleCSRFToken)).Methods(http.MethodGet)
+	r.HandleFunc("/api/guilds/{guildID:[0-9]+}/antinuke", requireGuildOwner(handleAntinukeGet)).Methods(http.MethodGet)
+	r.HandleFunc("/api/guilds/{guildID:[0-9]+}/antinuke", requireGuildOwner(handleAntinukePatch)).Methods(http.MethodPatch)
+	r.HandleFunc("/api/guilds/{guildID:[0-9]+}/whitelist", requireGuildOwner(handleWhitelistGet)).Methods(http.MethodGet)
+	r.HandleFunc("/api/guilds/{guildID:[0-9]+}/whitelist", requireGuildOwner(handleWhitelistPost)).Methods(http.MethodPost)
+	r.HandleFunc("/api/guilds/{guildID:[0-9]+}/whitelist/{userID:[0-9]+}", requireGuildOwner(handleWhitelistDelete)).Methods(http.MethodDelete)
+	r.HandleFunc("/api/guilds/{guildID:[0-9]+}/incidents", requireGuildOwner(handleIncidentsGet)).Methods(http.MethodGet)
+	r.HandleFunc("/api/guilds/{guildID:[0-9]+}/incidents/{incidentID:[0-9]+}", requireGuildOwner(handleIncidentGet)).Methods(http.MethodGet)
+
+	registerAPIv1(r)
+
+	r.HandleFunc("/ws/guild/{guildID:[0-9]+}/events", requireGuildOwner(handleEventStream)).Methods(http.MethodGet)
+	return nil
+}
+
+func init() {
+	// Session values are gob encoded
+	gob.Register(User{})
 }
 
 func handleLogin(w http.ResponseWriter, r *http.Request) {
-	state := generateRandomState()
+	state, err := randomToken()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
 	
 	session, _ := store.Get(r, "discord-auth")
+	if auth, _ := session.Values["authenticated"].(bool); !auth {
+		// Don't keep anonymous sessions around longer than a login takes
+		session.Options.MaxAge = int(stateTTL / time.Second)
+	}
 	session.Values["state"] = state
-	session.Save(r, w)
+	session.Values["state_expires"] = time.Now().Add(stateTTL).Unix()
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error saving OAuth state: %v", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
 	
 	url := oauthConfig.AuthCodeURL(state)
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
@@ -54,14 +126,18 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 
 func handleCallback(w http.ResponseWriter, r *http.Request) {
 	session, _ := store.Get(r, "discord-auth")
-	state := session.Values["state"]
 	
-	if r.URL.Query().Get("state") != state {
-		http.Error(w, "Invalid state parameter", http.StatusBadRequest)
+	// The state is single use, so a replayed callback fails here
+	if !consumeState(r, w, session, r.URL.Query().Get("state")) {
+		http.Error(w, "Invalid or expired state parameter", http.StatusBadRequest)
 		return
 	}
 	
 	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "Missing code parameter", http.StatusBadRequest)
+		return
+	}
 	token, err := oauthConfig.Exchange(r.Context(), code)
 	if err != nil {
 		http.Error(w, "Failed to exchange token: "+err.Error(), http.StatusInternalServerError)
@@ -82,27 +158,67 @@ func handleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	guilds, err := fetchManageableGuilds(client)
+	if err != nil {
+		http.Error(w, "Failed to get guilds: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := renewSession(session); err != nil {
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
+		return
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
+		return
+	}
+	session.Options.MaxAge = int(sessionMaxAge / time.Second)
+	session.Values[csrfField] = csrf
 	session.Values["user"] = user
+	session.Values["guilds"] = guilds
 	session.Values["authenticated"] = true
-	session.Save(r, w)
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error saving session: %v", err)
+		http.Error(w, "Failed to save session", http.StatusInternalServerError)
+		return
+	}
 	
 	http.Redirect(w, r, "/dashboard", http.StatusTemporaryRedirect)
 }
 
 func handleLogout(w http.ResponseWriter, r *http.Request) {
 	session, _ := store.Get(r, "discord-auth")
-	session.Values["authenticated"] = false
-	session.Values["user"] = nil
-	session.Save(r, w)
+	session.Options.MaxAge = -1
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error deleting session: %v", err)
+	}
 	
-	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func handleDashboard(w http.ResponseWriter, r *http.Request) {
+// handleLogoutAll revokes every session of the current user, on all
+// devices.
+func handleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	if err := revokeUserSessions(user.ID); err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
 	session, _ := store.Get(r, "discord-auth")
-	user := session.Values["user"].(User)
-	
-	fmt.Fprintf(w, "Welcome to your dashboard, %s!", user.Username)
+	session.Options.MaxAge = -1
+	session.Save(r, w)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// currentUser returns the logged in user from the session.
+func currentUser(r *http.Request) (User, bool) {
+	session, _ := store.Get(r, "discord-auth")
+	user, ok := session.Values["user"].(User)
+	return user, ok
 }
 
 func requireAuth(next http.HandlerFunc) http.HandlerFunc {
@@ -111,6 +227,10 @@ func requireAuth(next http.HandlerFunc) http.HandlerFunc {
 		auth, ok := session.Values["authenticated"].(bool)
 		
 		if !ok || !auth {
+			if isAPI(r) {
+				writeJSONError(w, http.StatusUnauthorized, "Not logged in")
+				return
+			}
 			http.Redirect(w, r, "/login", http.StatusTemporaryRedirect)
 			return
 		}
@@ -118,7 +238,3 @@ func requireAuth(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r)
 	}
 }
-
-func generateRandomState() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
-}
@@ -0,0 +1,57 @@
+package dashboard
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	testGuildID = "100000000000000001"
+	testOwnerID = "200000000000000001"
+)
+
+// setupTestDB points the dashboard at a fresh database with the session
+// store and API key table set up, and clears the rate limiters.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	database, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevDB, prevStore := db, store
+	db = database
+	t.Cleanup(func() {
+		database.Close()
+		db, store = prevDB, prevStore
+	})
+
+	if store, err = newSQLiteStore([]byte(strings.Repeat("s", 32)), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := createAPIKeyTable(); err != nil {
+		t.Fatal(err)
+	}
+
+	limiterMutex.Lock()
+	limiters = make(map[int64]*bucket)
+	limiterMutex.Unlock()
+}
+
+// setupTestGuild gives the bot a state holding one guild owned by ownerID.
+func setupTestGuild(t *testing.T, ownerID string) {
+	t.Helper()
+
+	state := discordgo.NewState()
+	if err := state.GuildAdd(&discordgo.Guild{ID: testGuildID, OwnerID: ownerID}); err != nil {
+		t.Fatal(err)
+	}
+	prev := bot
+	bot = &discordgo.Session{State: state}
+	t.Cleanup(func() { bot = prev })
+}
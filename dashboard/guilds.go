@@ -0,0 +1,179 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/mux"
+)
+
+// userGuild is a guild as returned by the OAuth /users/@me/guilds endpoint.
+type userGuild struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Owner       bool   `json:"owner"`
+	Permissions string `json:"permissions"`
+}
+
+// fetchManageableGuilds returns the IDs of the guilds the OAuth user can
+// manage and the bot is in.
+func fetchManageableGuilds(client *http.Client) ([]string, error) {
+	resp, err := client.Get("https://discord.com/api/users/@me/guilds")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var guilds []userGuild
+	if err := json.NewDecoder(resp.Body).Decode(&guilds); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, g := range guilds {
+		perms, _ := strconv.ParseInt(g.Permissions, 10, 64)
+		if !g.Owner && perms&(discordgo.PermissionAdministrator|discordgo.PermissionManageServer) == 0 {
+			continue
+		}
+		if _, err := bot.State.Guild(g.ID); err != nil {
+			continue
+		}
+		ids = append(ids, g.ID)
+	}
+	return ids, nil
+}
+
+// memberPermissions computes a member's guild-wide permissions from the
+// bot's view of the guild's roles.
+func memberPermissions(guild *discordgo.Guild, member *discordgo.Member) int64 {
+	if guild.OwnerID == member.User.ID {
+		return discordgo.PermissionAll
+	}
+
+	var perms int64
+	for _, role := range guild.Roles {
+		if role.ID == guild.ID {
+			perms |= role.Permissions
+			continue
+		}
+		for _, roleID := range member.Roles {
+			if roleID == role.ID {
+				perms |= role.Permissions
+				break
+			}
+		}
+	}
+
+	if perms&discordgo.PermissionAdministrator != 0 {
+		return discordgo.PermissionAll
+	}
+	return perms
+}
+
+// canManageGuild re-checks against the bot's state that the user may manage
+// the guild, so permissions revoked after login take effect immediately.
+func canManageGuild(guildID, userID string) (*discordgo.Guild, bool) {
+	guild, err := bot.State.Guild(guildID)
+	if err != nil {
+		return nil, false
+	}
+
+	member, err := bot.State.Member(guildID, userID)
+	if err != nil {
+		if member, err = bot.GuildMember(guildID, userID); err != nil {
+			return nil, false
+		}
+	}
+
+	return guild, memberPermissions(guild, member)&discordgo.PermissionManageServer != 0
+}
+
+// isAPI reports whether r is for a JSON or WebSocket endpoint, which get
+// JSON errors instead of pages and redirects.
+func isAPI(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/ws/")
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request, message string) {
+	if isAPI(r) {
+		writeJSONError(w, http.StatusForbidden, message)
+		return
+	}
+	http.Error(w, message, http.StatusForbidden)
+}
+
+// requireGuildAccess gates a per-guild page on the {guildID} route variable.
+func requireGuildAccess(next http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := currentUser(r)
+		if _, ok := canManageGuild(mux.Vars(r)["guildID"], user.ID); !ok {
+			forbidden(w, r, "You don't have permission to manage this server")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// requireGuildOwner gates a per-guild page on the user owning the guild,
+// matching the owner-only Anti-Nuke commands in Discord.
+func requireGuildOwner(next http.HandlerFunc) http.HandlerFunc {
+	return requireGuildAccess(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := currentUser(r)
+		guild, err := bot.State.Guild(mux.Vars(r)["guildID"])
+		if err != nil || guild.OwnerID != user.ID {
+			forbidden(w, r, "Only the server owner can manage Anti-Nuke")
+			return
+		}
+		next(w, r)
+	})
+}
+
+type guildSummary struct {
+	ID          string
+	Name        string
+	Icon        string
+	MemberCount int
+}
+
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+
+	session, _ := store.Get(r, "discord-auth")
+	ids, _ := session.Values["guilds"].([]string)
+
+	var guilds []guildSummary
+	for _, id := range ids {
+		guild, ok := canManageGuild(id, user.ID)
+		if !ok {
+			continue
+		}
+		summary := guildSummary{ID: guild.ID, Name: guild.Name, MemberCount: guild.MemberCount}
+		if guild.Icon != "" {
+			summary.Icon = guild.IconURL("64")
+		}
+		guilds = append(guilds, summary)
+	}
+
+	render(w, r, "guilds", map[string]interface{}{
+		"User":   user,
+		"Guilds": guilds,
+	})
+}
+
+func handleGuild(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	guild, _ := bot.State.Guild(mux.Vars(r)["guildID"])
+
+	render(w, r, "guild", map[string]interface{}{
+		"User":  user,
+		"Guild": guild,
+	})
+}
@@ -0,0 +1,169 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"aware/antinuke"
+
+	"github.com/gorilla/mux"
+)
+
+const filterDateLayout = "2006-01-02"
+
+// parseIncidentFilter reads the timeline filters from the query string.
+// Dates are whole UTC days, and "until" includes the day given.
+func parseIncidentFilter(query url.Values) (antinuke.IncidentFilter, error) {
+	filter := antinuke.IncidentFilter{Action: query.Get("action")}
+
+	if actor := query.Get("actor"); actor != "" {
+		userID, ok := parseWhitelistUser(actor)
+		if !ok {
+			return filter, fmt.Errorf("actor must be a user ID")
+		}
+		filter.ActorID = userID
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(filterDateLayout, since)
+		if err != nil {
+			return filter, fmt.Errorf("since must be a date like 2006-01-02")
+		}
+		filter.Since = t
+	}
+	if until := query.Get("until"); until != "" {
+		t, err := time.Parse(filterDateLayout, until)
+		if err != nil {
+			return filter, fmt.Errorf("until must be a date like 2006-01-02")
+		}
+		filter.Until = t.AddDate(0, 0, 1)
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return filter, fmt.Errorf("limit must be a positive number")
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// incidentFromRequest loads the incident named by the {incidentID} route
+// variable, writing the error response itself when it can't.
+func incidentFromRequest(w http.ResponseWriter, r *http.Request) (*antinuke.Incident, bool) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["incidentID"], 10, 64)
+
+	incident, err := antinuke.GetIncident(vars["guildID"], id)
+	if err == antinuke.ErrIncidentNotFound {
+		if isAPI(r) {
+			writeJSONError(w, http.StatusNotFound, "Incident not found")
+		} else {
+			http.NotFound(w, r)
+		}
+		return nil, false
+	}
+	if err != nil {
+		if isAPI(r) {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to load incident")
+		} else {
+			http.Error(w, "Failed to load incident", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return incident, true
+}
+
+func handleIncidentsPage(w http.ResponseWriter, r *http.Request) {
+	guildID := mux.Vars(r)["guildID"]
+	user, _ := currentUser(r)
+	guild, _ := bot.State.Guild(guildID)
+
+	status, message := http.StatusOK, ""
+	filter, err := parseIncidentFilter(r.URL.Query())
+	if err != nil {
+		status, message = http.StatusBadRequest, err.Error()
+		filter = antinuke.IncidentFilter{}
+	}
+
+	incidents, err := antinuke.ListIncidents(guildID, filter)
+	if err != nil {
+		http.Error(w, "Failed to load incidents", http.StatusInternalServerError)
+		return
+	}
+	actions, _ := antinuke.IncidentActions(guildID)
+
+	actors := make(map[string]userInfo)
+	for _, incident := range incidents {
+		if _, ok := actors[incident.ActorID]; !ok {
+			actors[incident.ActorID] = resolveUser(guildID, incident.ActorID)
+		}
+	}
+
+	renderStatus(w, r, status, "incidents", map[string]interface{}{
+		"User":      user,
+		"Guild":     guild,
+		"Incidents": incidents,
+		"Actors":    actors,
+		"Actions":   actions,
+		"Query":     r.URL.Query(),
+		"Message":   message,
+		"Failed":    status != http.StatusOK,
+	})
+}
+
+func handleIncidentPage(w http.ResponseWriter, r *http.Request) {
+	incident, ok := incidentFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user, _ := currentUser(r)
+	guild, _ := bot.State.Guild(incident.GuildID)
+	render(w, r, "incident", map[string]interface{}{
+		"User":     user,
+		"Guild":    guild,
+		"Incident": incident,
+		"Actor":    resolveUser(incident.GuildID, incident.ActorID),
+	})
+}
+
+// handleIncidentExport downloads an incident and its events as JSON.
+func handleIncidentExport(w http.ResponseWriter, r *http.Request) {
+	incident, ok := incidentFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="incident-%d.json"`, incident.ID))
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(incident)
+}
+
+func handleIncidentsGet(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseIncidentFilter(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	incidents, err := antinuke.ListIncidents(mux.Vars(r)["guildID"], filter)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load incidents")
+		return
+	}
+	writeJSON(w, http.StatusOK, incidents)
+}
+
+func handleIncidentGet(w http.ResponseWriter, r *http.Request) {
+	incident, ok := incidentFromRequest(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, incident)
+}
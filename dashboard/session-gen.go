@@ -12,8 +12,4 @@ func generateSessionSecret() (string, error) {
         return "", err
     }
     return base64.StdEncoding.EncodeToString(b), nil
-}
-
-func main() {
-	generateSessionSecret()
 }
\ No newline at end of file
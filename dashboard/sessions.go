@@ -0,0 +1,255 @@
+package dashboard
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/gob"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// sessionMaxAge is how long a login lasts before the user has to sign in
+// again.
+const sessionMaxAge = 7 * 24 * time.Hour
+
+// sqliteStore keeps session values in the database and only a signed
+// session ID in the cookie, so sessions can be revoked server-side.
+type sqliteStore struct {
+	codecs  []securecookie.Codec
+	options sessions.Options
+}
+
+// newSQLiteStore creates the session table. Cookies are only sent over
+// HTTPS when secure is set.
+func newSQLiteStore(secret []byte, secure bool) (*sqliteStore, error) {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS dashboard_sessions (
+            id TEXT PRIMARY KEY,
+            user_id TEXT,
+            data BLOB NOT NULL,
+            created_at TEXT NOT NULL,
+            expires_at TEXT NOT NULL
+        )
+    `)
+	if err != nil {
+		return nil, err
+	}
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_dashboard_sessions_user ON dashboard_sessions (user_id)")
+
+	return &sqliteStore{
+		codecs: securecookie.CodecsFromPairs(secret),
+		options: sessions.Options{
+			Path:     "/",
+			MaxAge:   int(sessionMaxAge / time.Second),
+			Secure:   secure,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+	}, nil
+}
+
+// Get returns the request's cached session, loading it on first use.
+func (s *sqliteStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request's cookie, or returns a new
+// empty session if there is none or it has expired or been revoked.
+func (s *sqliteStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := s.options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var id string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &id, s.codecs...); err != nil {
+		return session, nil
+	}
+
+	var data []byte
+	err = db.QueryRow("SELECT data FROM dashboard_sessions WHERE id = ? AND expires_at > ?",
+		id, formatSessionTime(time.Now())).Scan(&data)
+	if err == sql.ErrNoRows {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&session.Values); err != nil {
+		return session, err
+	}
+	session.ID = id
+	session.IsNew = false
+	return session, nil
+}
+
+// Save stores the session and sets its cookie. A negative MaxAge deletes
+// the session.
+func (s *sqliteStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if _, err := db.Exec("DELETE FROM dashboard_sessions WHERE id = ?", session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		id, err := randomToken()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(session.Values); err != nil {
+		return err
+	}
+
+	var userID interface{}
+	if user, ok := session.Values["user"].(User); ok {
+		userID = user.ID
+	}
+
+	now := time.Now()
+	expires := now.Add(time.Duration(session.Options.MaxAge) * time.Second)
+	_, err := db.Exec(`
+        INSERT INTO dashboard_sessions (id, user_id, data, created_at, expires_at)
+        VALUES (?, ?, ?, ?, ?)
+        ON CONFLICT(id) DO UPDATE SET user_id = excluded.user_id, data = excluded.data, expires_at = excluded.expires_at`,
+		session.ID, userID, data.Bytes(), formatSessionTime(now), formatSessionTime(expires))
+	if err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// renewSession moves the session to a fresh ID, so an ID planted before
+// login can't be used to ride the logged in session.
+func renewSession(session *sessions.Session) error {
+	if session.ID != "" {
+		if _, err := db.Exec("DELETE FROM dashboard_sessions WHERE id = ?", session.ID); err != nil {
+			return err
+		}
+	}
+	session.ID = ""
+	return nil
+}
+
+// sessionActive reports whether a session still exists and hasn't expired.
+func sessionActive(id string) bool {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM dashboard_sessions WHERE id = ? AND expires_at > ?",
+		id, formatSessionTime(time.Now())).Scan(&count)
+	return err == nil && count > 0
+}
+
+// revokeUserSessions deletes every session belonging to userID.
+func revokeUserSessions(userID string) error {
+	_, err := db.Exec("DELETE FROM dashboard_sessions WHERE user_id = ?", userID)
+	return err
+}
+
+// purgeExpiredSessions deletes expired sessions and returns how many were
+// removed.
+func purgeExpiredSessions() (int64, error) {
+	res, err := db.Exec("DELETE FROM dashboard_sessions WHERE expires_at <= ?", formatSessionTime(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// StartSessionPurge deletes expired sessions every interval until the
+// returned stop function is called.
+func StartSessionPurge(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := purgeExpiredSessions(); err != nil {
+					log.Printf("Error purging expired sessions: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func formatSessionTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// randomToken returns a random URL-safe token, used for session IDs, OAuth
+// state and CSRF tokens.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// loadSessionSecret returns the configured secret, or the secret persisted
+// in the database, generating and persisting one on first start.
+func loadSessionSecret(configured string) ([]byte, error) {
+	if configured != "" {
+		return []byte(configured), nil
+	}
+
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS dashboard_secrets (
+            name TEXT PRIMARY KEY,
+            value TEXT NOT NULL
+        )
+    `)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret string
+	err = db.QueryRow("SELECT value FROM dashboard_secrets WHERE name = 'session_secret'").Scan(&secret)
+	if err == nil {
+		return []byte(secret), nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if secret, err = generateSessionSecret(); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("INSERT INTO dashboard_secrets (name, value) VALUES ('session_secret', ?)", secret); err != nil {
+		return nil, err
+	}
+	log.Println("SESSION_SECRET is not set, generated and saved a new session secret")
+	return []byte(secret), nil
+}
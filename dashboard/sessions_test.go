@@ -0,0 +1,147 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// loadTestSession loads the session a cookie refers to.
+func loadTestSession(t *testing.T, cookie *http.Cookie) *sessions.Session {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	session, err := store.New(r, "discord-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	setupTestDB(t)
+	cookie := saveTestSession(t, map[interface{}]interface{}{"user": User{ID: testOwnerID}})
+
+	session := loadTestSession(t, cookie)
+	if session.IsNew {
+		t.Fatal("the saved session wasn't found")
+	}
+	if user, _ := session.Values["user"].(User); user.ID != testOwnerID {
+		t.Errorf("user = %+v, want %s", session.Values["user"], testOwnerID)
+	}
+
+	// The cookie only carries a signed ID
+	cookie.Value = cookie.Value[:len(cookie.Value)-2] + "xx"
+	if !loadTestSession(t, cookie).IsNew {
+		t.Error("a tampered cookie loaded the session")
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	setupTestDB(t)
+	expired := saveTestSession(t, map[interface{}]interface{}{"user": User{ID: testOwnerID}})
+	active := saveTestSession(t, map[interface{}]interface{}{"user": User{ID: testOwnerID}})
+
+	id := loadTestSession(t, expired).ID
+	past := formatSessionTime(time.Now().Add(-time.Minute))
+	if _, err := db.Exec("UPDATE dashboard_sessions SET expires_at = ? WHERE id = ?", past, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if !loadTestSession(t, expired).IsNew || sessionActive(id) {
+		t.Error("an expired session was still loaded")
+	}
+	if n, err := purgeExpiredSessions(); err != nil || n != 1 {
+		t.Errorf("purgeExpiredSessions() = %d, %v, want 1 removed", n, err)
+	}
+	if loadTestSession(t, active).IsNew {
+		t.Error("purging removed an active session")
+	}
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	setupTestDB(t)
+	const otherID = "200000000000000002"
+	first := saveTestSession(t, map[interface{}]interface{}{"user": User{ID: testOwnerID}})
+	second := saveTestSession(t, map[interface{}]interface{}{"user": User{ID: testOwnerID}})
+	other := saveTestSession(t, map[interface{}]interface{}{"user": User{ID: otherID}})
+
+	if err := revokeUserSessions(testOwnerID); err != nil {
+		t.Fatal(err)
+	}
+	if !loadTestSession(t, first).IsNew || !loadTestSession(t, second).IsNew {
+		t.Error("the user's sessions weren't revoked")
+	}
+	if loadTestSession(t, other).IsNew {
+		t.Error("another user's session was revoked")
+	}
+}
+
+func TestRenewSession(t *testing.T) {
+	setupTestDB(t)
+	planted := saveTestSession(t, map[interface{}]interface{}{"state": "state"})
+
+	// What the callback does on login
+	session := loadTestSession(t, planted)
+	oldID := session.ID
+	if err := renewSession(session); err != nil {
+		t.Fatal(err)
+	}
+	session.Values["user"] = User{ID: testOwnerID}
+	w := httptest.NewRecorder()
+	if err := session.Save(httptest.NewRequest(http.MethodGet, "/callback", nil), w); err != nil {
+		t.Fatal(err)
+	}
+
+	if session.ID == oldID || sessionActive(oldID) {
+		t.Error("the session kept its pre-login ID")
+	}
+	if !loadTestSession(t, planted).IsNew {
+		t.Error("the pre-login cookie still loads a session")
+	}
+	if user, _ := loadTestSession(t, sessionCookie(t, w)).Values["user"].(User); user.ID != testOwnerID {
+		t.Error("the renewed cookie doesn't load the logged in session")
+	}
+}
+
+func TestSessionCookieOptions(t *testing.T) {
+	for _, secure := range []bool{false, true} {
+		setupTestDB(t)
+		var err error
+		if store, err = newSQLiteStore([]byte(strings.Repeat("s", 32)), secure); err != nil {
+			t.Fatal(err)
+		}
+
+		cookie := saveTestSession(t, nil)
+		if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Secure != secure || cookie.Path != "/" {
+			t.Errorf("secure=%v: cookie = %+v, want HttpOnly, SameSite=Lax, Secure=%v and Path=/", secure, cookie, secure)
+		}
+		if want := int(sessionMaxAge / time.Second); cookie.MaxAge != want {
+			t.Errorf("secure=%v: MaxAge = %d, want %d", secure, cookie.MaxAge, want)
+		}
+	}
+}
+
+func TestSessionDelete(t *testing.T) {
+	setupTestDB(t)
+	cookie := saveTestSession(t, map[interface{}]interface{}{"user": User{ID: testOwnerID}})
+
+	// What logout does
+	session := loadTestSession(t, cookie)
+	session.Options.MaxAge = -1
+	w := httptest.NewRecorder()
+	if err := session.Save(httptest.NewRequest(http.MethodPost, "/logout", nil), w); err != nil {
+		t.Fatal(err)
+	}
+
+	if sessionActive(session.ID) {
+		t.Error("the deleted session is still stored")
+	}
+	if cleared := sessionCookie(t, w); cleared.MaxAge >= 0 || cleared.Value != "" {
+		t.Errorf("cookie = %+v, want it cleared", cleared)
+	}
+}
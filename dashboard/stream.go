@@ -0,0 +1,93 @@
+package dashboard
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"aware/events"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamWriteWait = 10 * time.Second
+	streamPongWait  = 60 * time.Second
+	// streamPingPeriod must be shorter than streamPongWait. Access and the
+	// session are re-checked on every ping so revoked users are disconnected.
+	streamPingPeriod = 30 * time.Second
+)
+
+// upgrader keeps gorilla's default same-origin check, so other sites can't
+// open a stream with the user's session cookie.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// stillOwner reports whether userID still owns the guild.
+func stillOwner(guildID, userID string) bool {
+	guild, err := bot.State.Guild(guildID)
+	return err == nil && guild.OwnerID == userID
+}
+
+// handleEventStream streams the guild's events from the bus as JSON
+// messages until the client disconnects or loses access.
+func handleEventStream(w http.ResponseWriter, r *http.Request) {
+	guildID := mux.Vars(r)["guildID"]
+	user, _ := currentUser(r)
+	session, _ := store.Get(r, "discord-auth")
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Error upgrading event stream: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	stream, unsubscribe := events.Subscribe(guildID)
+	defer unsubscribe()
+
+	// The stream is one-way; reading only handles pongs and notices when
+	// the client goes away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(streamPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-stream:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if !sessionActive(session.ID) || !stillOwner(guildID, user.ID) {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Access revoked"),
+					time.Now().Add(streamWriteWait))
+				return
+			}
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
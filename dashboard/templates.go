@@ -0,0 +1,224 @@
+package dashboard
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+var templates = template.Must(template.New("").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Aware Dashboard</title>
+<style>
+body { font-family: sans-serif; background: #1e1f22; color: #dbdee1; max-width: 960px; margin: 0 auto; padding: 24px; }
+a { color: #00a8fc; }
+nav { display: flex; justify-content: space-between; margin-bottom: 24px; }
+.guild { display: flex; align-items: center; gap: 12px; padding: 12px; background: #2b2d31; border-radius: 8px; margin-bottom: 8px; }
+.guild img { width: 48px; height: 48px; border-radius: 50%; }
+.avatar { width: 24px; height: 24px; border-radius: 50%; vertical-align: middle; margin-right: 6px; }
+table { width: 100%; border-collapse: collapse; }
+td, th { text-align: left; padding: 8px; border-bottom: 1px solid #3f4147; }
+.error { color: #f23f43; }
+.success { color: #23a55a; }
+nav form { display: inline; }
+</style>
+</head>
+<body>
+<nav><a href="/dashboard">Aware</a><span>{{.User.Username}} · <form method="post" action="/logout"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><button type="submit">Log out</button></form> · <form method="post" action="/logout/all"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><button type="submit">Log out everywhere</button></form></span></nav>
+{{end}}
+
+{{define "footer"}}</body>
+</html>{{end}}
+
+{{define "guilds"}}{{template "header" .}}
+<h1>Select a server</h1>
+{{range .Guilds}}
+<a class="guild" href="/dashboard/{{.ID}}">
+{{if .Icon}}<img src="{{.Icon}}" alt="">{{end}}
+<span>{{.Name}}<br><small>{{.MemberCount}} members</small></span>
+</a>
+{{else}}
+<p>No servers found. You need the Manage Server permission in a server the bot is in.</p>
+{{end}}
+{{template "footer" .}}{{end}}
+
+{{define "guild"}}{{template "header" .}}
+<h1>{{.Guild.Name}}</h1>
+<p>{{.Guild.MemberCount}} members</p>
+{{if eq .Guild.OwnerID .User.ID}}
+<ul>
+<li><a href="/dashboard/{{.Guild.ID}}/antinuke">Anti-Nuke settings</a></li>
+<li><a href="/dashboard/{{.Guild.ID}}/whitelist">Anti-Nuke whitelist</a></li>
+<li><a href="/dashboard/{{.Guild.ID}}/incidents">Incident timeline</a></li>
+<li><a href="/dashboard/{{.Guild.ID}}/api-keys">API keys</a></li>
+</ul>
+{{else}}
+<p>Anti-Nuke settings can only be managed by the server owner.</p>
+{{end}}
+{{template "footer" .}}{{end}}
+
+{{define "antinuke"}}{{template "header" .}}
+<h1><a href="/dashboard/{{.Guild.ID}}">{{.Guild.Name}}</a> · Anti-Nuke</h1>
+{{if .Message}}<p class="{{if .Failed}}error{{else}}success{{end}}">{{.Message}}</p>{{end}}
+<form method="post">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<table>
+<tr><th>Actions per minute</th><td><input type="number" name="actions_per_minute" min="1" max="99" value="{{.Config.ActionsPerMinute}}"></td></tr>
+<tr><th>Actions per hour</th><td><input type="number" name="actions_per_hour" min="1" max="999" value="{{.Config.ActionsPerHour}}"></td></tr>
+<tr><th>Punishment</th><td><select name="punishment_type">
+{{range .Punishments}}<option value="{{.}}"{{if eq . $.Config.PunishmentType}} selected{{end}}>{{.}}</option>{{end}}
+</select></td></tr>
+<tr><th>Mode</th><td><select name="mode">
+{{range .Modes}}<option value="{{.}}"{{if eq . $.Config.Mode}} selected{{end}}>{{.}}</option>{{end}}
+</select></td></tr>
+<tr><th>Logs channel</th><td><select name="logs_channel_id">
+{{if not .Config.LogsChannelID}}<option value="">Not set up</option>{{end}}
+{{range .Channels}}<option value="{{.ID}}"{{if eq .ID $.Config.LogsChannelID}} selected{{end}}>#{{.Name}}</option>{{end}}
+</select></td></tr>
+<tr><th>Mod logs channel</th><td><select name="mod_logs_channel_id">
+{{if not .Config.ModLogsChannelID}}<option value="">Not set up</option>{{end}}
+{{range .Channels}}<option value="{{.ID}}"{{if eq .ID $.Config.ModLogsChannelID}} selected{{end}}>#{{.Name}}</option>{{end}}
+</select></td></tr>
+</table>
+<p><button type="submit">Save</button></p>
+</form>
+<p><a href="/dashboard/{{.Guild.ID}}/whitelist">Manage whitelist</a> · <a href="/dashboard/{{.Guild.ID}}/incidents">Incident timeline</a></p>
+{{template "footer" .}}{{end}}
+
+{{define "whitelist"}}{{template "header" .}}
+<h1><a href="/dashboard/{{.Guild.ID}}">{{.Guild.Name}}</a> · Whitelist</h1>
+{{if .Message}}<p class="{{if .Failed}}error{{else}}success{{end}}">{{.Message}}</p>{{end}}
+<table>
+<tr><th>User</th><th>Added by</th><th>Added</th><th>Expires</th><th></th></tr>
+{{range .Whitelist}}
+<tr>
+<td>{{if .User.Avatar}}<img class="avatar" src="{{.User.Avatar}}" alt="">{{end}}{{.User.Username}}<br><small>{{.UserID}}</small></td>
+<td>{{.AddedByUser.Username}}</td>
+<td>{{if not .AddedAt.IsZero}}{{.AddedAt.Format "2006-01-02 15:04"}}{{end}}</td>
+<td>{{if .ExpiresAt}}{{.ExpiresAt.Format "2006-01-02 15:04"}}{{else}}Never{{end}}</td>
+<td><form method="post" action="/dashboard/{{$.Guild.ID}}/whitelist/{{.UserID}}/remove"><input type="hidden" name="csrf_token" value="{{$.CSRFToken}}"><button type="submit">Remove</button></form></td>
+</tr>
+{{else}}
+<tr><td colspan="5">No users in whitelist.</td></tr>
+{{end}}
+</table>
+<h2>Add user</h2>
+<form method="post">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<input type="text" name="user_id" placeholder="User ID" required>
+<input type="text" name="duration" placeholder="Duration, e.g. 12h (optional)">
+<button type="submit">Add</button>
+</form>
+{{template "footer" .}}{{end}}
+
+{{define "apikeys"}}{{template "header" .}}
+<h1><a href="/dashboard/{{.Guild.ID}}">{{.Guild.Name}}</a> · API keys</h1>
+{{if .Message}}<p class="{{if .Failed}}error{{else}}success{{end}}">{{.Message}}</p>{{end}}
+{{if .NewKey}}<p><code>{{.NewKey}}</code></p>{{end}}
+<table>
+<tr><th>Name</th><th>Scope</th><th>Key</th><th>Created by</th><th>Created</th><th>Last used</th><th></th></tr>
+{{range .Keys}}
+<tr>
+<td>{{.Name}}</td>
+<td>{{.Scope}}</td>
+<td>aw_…{{.Hint}}</td>
+<td>{{(index $.Creators .CreatedBy).Username}}</td>
+<td>{{.CreatedAt.Format "2006-01-02 15:04 MST"}}</td>
+<td>{{if .LastUsedAt}}{{.LastUsedAt.Format "2006-01-02 15:04 MST"}}{{else}}Never{{end}}</td>
+<td><form method="post" action="/dashboard/{{$.Guild.ID}}/api-keys/{{.ID}}/revoke"><input type="hidden" name="csrf_token" value="{{$.CSRFToken}}"><button type="submit">Revoke</button></form></td>
+</tr>
+{{else}}
+<tr><td colspan="7">No API keys.</td></tr>
+{{end}}
+</table>
+<h2>Create key</h2>
+<form method="post">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<input type="text" name="name" placeholder="Name" maxlength="64" required>
+<select name="scope"><option value="read">read</option><option value="write">write</option></select>
+<button type="submit">Create</button>
+</form>
+{{template "footer" .}}{{end}}
+
+{{define "incidents"}}{{template "header" .}}
+<h1><a href="/dashboard/{{.Guild.ID}}">{{.Guild.Name}}</a> · Incidents</h1>
+{{if .Message}}<p class="{{if .Failed}}error{{else}}success{{end}}">{{.Message}}</p>{{end}}
+<form method="get">
+<input type="text" name="actor" placeholder="Actor ID" value="{{.Query.Get "actor"}}">
+<select name="action">
+<option value="">All actions</option>
+{{range .Actions}}<option value="{{.}}"{{if eq . ($.Query.Get "action")}} selected{{end}}>{{.}}</option>{{end}}
+</select>
+<input type="date" name="since" value="{{.Query.Get "since"}}">
+<input type="date" name="until" value="{{.Query.Get "until"}}">
+<button type="submit">Filter</button>
+</form>
+<table>
+<tr><th>Started</th><th>Actor</th><th>Action</th><th>Reason</th><th>Events</th></tr>
+{{range .Incidents}}
+{{$actor := index $.Actors .ActorID}}
+<tr>
+<td><a href="/dashboard/{{$.Guild.ID}}/incidents/{{.ID}}">{{.CreatedAt.Format "2006-01-02 15:04:05 MST"}}</a></td>
+<td>{{if $actor.Avatar}}<img class="avatar" src="{{$actor.Avatar}}" alt="">{{end}}{{$actor.Username}}</td>
+<td>{{.Action}}</td>
+<td>{{.Reason}}</td>
+<td>{{.EventCount}}</td>
+</tr>
+{{else}}
+<tr><td colspan="5">No incidents recorded.</td></tr>
+{{end}}
+</table>
+<h2>Live events</h2>
+<table id="live"><tr><th>Time</th><th>Event</th><th>Details</th></tr></table>
+<script>
+(function() {
+	var proto = location.protocol === "https:" ? "wss://" : "ws://";
+	var ws = new WebSocket(proto + location.host + "/ws/guild/{{.Guild.ID}}/events");
+	ws.onmessage = function(msg) {
+		var event = JSON.parse(msg.data);
+		var row = document.getElementById("live").insertRow(1);
+		row.insertCell().textContent = new Date(event.time).toLocaleString();
+		row.insertCell().textContent = event.type;
+		row.insertCell().textContent = JSON.stringify(event.data);
+	};
+})();
+</script>
+{{template "footer" .}}{{end}}
+
+{{define "incident"}}{{template "header" .}}
+<h1><a href="/dashboard/{{.Guild.ID}}/incidents">Incidents</a> · #{{.Incident.ID}}</h1>
+<p>{{if .Actor.Avatar}}<img class="avatar" src="{{.Actor.Avatar}}" alt="">{{end}}{{.Actor.Username}} <small>{{.Incident.ActorID}}</small></p>
+<p>{{.Incident.Action}}: {{.Incident.Reason}}</p>
+<p><a href="/dashboard/{{.Guild.ID}}/incidents/{{.Incident.ID}}/export">Export as JSON</a></p>
+<table>
+<tr><th>Time</th><th>Type</th><th>Action</th><th>Detail</th></tr>
+{{range .Incident.Events}}
+<tr>
+<td>{{.CreatedAt.Format "2006-01-02 15:04:05 MST"}}</td>
+<td>{{.Type}}</td>
+<td>{{.Action}}</td>
+<td>{{.Detail}}</td>
+</tr>
+{{end}}
+</table>
+{{template "footer" .}}{{end}}
+`))
+
+// render executes a page template, adding the CSRF token its forms need.
+func render(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) {
+	renderStatus(w, r, http.StatusOK, name, data)
+}
+
+// renderStatus is like render with a status other than 200. The headers,
+// including any session cookie, are set before the status is written.
+func renderStatus(w http.ResponseWriter, r *http.Request, status int, name string, data map[string]interface{}) {
+	data["CSRFToken"] = csrfToken(w, r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := templates.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Error rendering %s: %v", name, err)
+	}
+}
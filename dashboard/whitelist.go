@@ -0,0 +1,195 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"aware/antinuke"
+
+	"github.com/gorilla/mux"
+)
+
+var snowflakePattern = regexp.MustCompile(`^[0-9]{17,20}$`)
+
+// userInfo is a user's display details, resolved from the bot's state.
+type userInfo struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+}
+
+// whitelistedUser is a whitelist entry with its users resolved.
+type whitelistedUser struct {
+	antinuke.WhitelistEntry
+	User        userInfo `json:"user"`
+	AddedByUser userInfo `json:"added_by_user"`
+}
+
+// resolveUser looks a user up in the bot's state, falling back to the bare
+// ID for users it doesn't have cached.
+func resolveUser(guildID, userID string) userInfo {
+	info := userInfo{ID: userID, Username: userID}
+	if userID == "" {
+		return info
+	}
+
+	member, err := bot.State.Member(guildID, userID)
+	if err != nil || member.User == nil {
+		return info
+	}
+
+	info.Username = member.User.Username
+	info.Avatar = member.User.AvatarURL("64")
+	return info
+}
+
+func whitelistedUsers(guildID string) ([]whitelistedUser, error) {
+	entries, err := antinuke.Whitelist(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]whitelistedUser, 0, len(entries))
+	for _, entry := range entries {
+		users = append(users, whitelistedUser{
+			WhitelistEntry: entry,
+			User:           resolveUser(guildID, entry.UserID),
+			AddedByUser:    resolveUser(guildID, entry.AddedBy),
+		})
+	}
+	return users, nil
+}
+
+// parseWhitelistUser accepts a user ID or mention.
+func parseWhitelistUser(value string) (string, bool) {
+	userID := strings.Trim(strings.TrimSpace(value), "<@!>")
+	return userID, snowflakePattern.MatchString(userID)
+}
+
+// parseWhitelistDuration parses an optional duration such as 30m or 12h.
+// An empty value whitelists permanently.
+func parseWhitelistDuration(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, true
+	}
+	d, err := time.ParseDuration(value)
+	return d, err == nil && d > 0
+}
+
+func renderWhitelist(w http.ResponseWriter, r *http.Request, status int, message string) {
+	guildID := mux.Vars(r)["guildID"]
+	user, _ := currentUser(r)
+	guild, _ := bot.State.Guild(guildID)
+
+	users, err := whitelistedUsers(guildID)
+	if err != nil {
+		http.Error(w, "Failed to load whitelist", http.StatusInternalServerError)
+		return
+	}
+
+	renderStatus(w, r, status, "whitelist", map[string]interface{}{
+		"User":      user,
+		"Guild":     guild,
+		"Whitelist": users,
+		"Message":   message,
+		"Failed":    status != http.StatusOK,
+	})
+}
+
+func handleWhitelistPage(w http.ResponseWriter, r *http.Request) {
+	message := ""
+	switch {
+	case r.URL.Query().Get("added") != "":
+		message = "User added to the whitelist"
+	case r.URL.Query().Get("removed") != "":
+		message = "User removed from the whitelist"
+	}
+	renderWhitelist(w, r, http.StatusOK, message)
+}
+
+func handleWhitelistAddForm(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderWhitelist(w, r, http.StatusBadRequest, "Invalid form")
+		return
+	}
+
+	userID, ok := parseWhitelistUser(r.PostForm.Get("user_id"))
+	if !ok {
+		renderWhitelist(w, r, http.StatusBadRequest, "Enter a user ID or mention")
+		return
+	}
+	duration, ok := parseWhitelistDuration(r.PostForm.Get("duration"))
+	if !ok {
+		renderWhitelist(w, r, http.StatusBadRequest, "Invalid duration, use a value like 30m or 12h")
+		return
+	}
+
+	if err := antinuke.AddToWhitelist(bot, mux.Vars(r)["guildID"], userID, actorID(r), duration); err != nil {
+		renderWhitelist(w, r, updateStatus(err), err.Error())
+		return
+	}
+
+	http.Redirect(w, r, r.URL.Path+"?added=1", http.StatusSeeOther)
+}
+
+func handleWhitelistRemoveForm(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := antinuke.RemoveFromWhitelist(bot, vars["guildID"], vars["userID"], actorID(r)); err != nil {
+		renderWhitelist(w, r, updateStatus(err), err.Error())
+		return
+	}
+
+	http.Redirect(w, r, "/dashboard/"+vars["guildID"]+"/whitelist?removed=1", http.StatusSeeOther)
+}
+
+func handleWhitelistGet(w http.ResponseWriter, r *http.Request) {
+	users, err := whitelistedUsers(mux.Vars(r)["guildID"])
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load whitelist")
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+func handleWhitelistPost(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		UserID   string `json:"user_id"`
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	userID, ok := parseWhitelistUser(body.UserID)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "user_id must be a user ID")
+		return
+	}
+	duration, ok := parseWhitelistDuration(body.Duration)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "duration must be a value like 30m or 12h")
+		return
+	}
+
+	guildID := mux.Vars(r)["guildID"]
+	if err := antinuke.AddToWhitelist(bot, guildID, userID, actorID(r), duration); err != nil {
+		writeJSONError(w, updateStatus(err), err.Error())
+		return
+	}
+
+	handleWhitelistGet(w, r)
+}
+
+func handleWhitelistDelete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := antinuke.RemoveFromWhitelist(bot, vars["guildID"], vars["userID"], actorID(r)); err != nil {
+		writeJSONError(w, updateStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
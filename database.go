@@ -9,13 +9,12 @@ import (
 
 var db *sql.DB
 
-func initDB() {
+func initDB(dbPath string) {
     var err error
     
     cwd, _ := os.Getwd()
     log.Printf("Current working directory: %s", cwd)
     
-    dbPath := "./main.db"
     log.Printf("Opening database at: %s", dbPath)
     
     db, err = sql.Open("sqlite", dbPath)
@@ -35,6 +34,7 @@ func initDB() {
         user_id TEXT,
         added_by TEXT,
         added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        expires_at TIMESTAMP,
         PRIMARY KEY (guild_id, user_id)
     )
 `)
@@ -53,7 +53,8 @@ if err != nil {
             quarantine_role_id TEXT,
             webhook_id TEXT,
             mod_webhook_id TEXT,
-            enabled BOOLEAN DEFAULT true
+            enabled BOOLEAN DEFAULT true,
+            monitor_mode BOOLEAN DEFAULT false
         )
     `)
     if err != nil {
@@ -0,0 +1,110 @@
+// Package events is an in-process pub/sub bus for guild events, used to feed
+// live views such as the dashboard's event stream.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published on the bus.
+const (
+	TypeDetection       = "antinuke.detection"
+	TypePunishment      = "antinuke.punishment"
+	TypeRollback        = "antinuke.rollback"
+	TypeWhitelistAdd    = "whitelist.add"
+	TypeWhitelistRemove = "whitelist.remove"
+	TypeConfigUpdate    = "config.update"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events are dropped for it.
+const subscriberBuffer = 64
+
+// Event is something that happened in a guild.
+type Event struct {
+	Type    string      `json:"type"`
+	GuildID string      `json:"guild_id"`
+	Time    time.Time   `json:"time"`
+	Data    interface{} `json:"data"`
+}
+
+// IncidentData is the payload of antinuke detection, punishment and
+// rollback events.
+type IncidentData struct {
+	IncidentID int64  `json:"incident_id"`
+	ActorID    string `json:"actor_id"`
+	Action     string `json:"action"`
+	Detail     string `json:"detail"`
+}
+
+// WhitelistData is the payload of whitelist events. ExpiresAt is nil for
+// permanent entries and removals.
+type WhitelistData struct {
+	UserID    string     `json:"user_id"`
+	ByID      string     `json:"by_id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ConfigData is the payload of config update events.
+type ConfigData struct {
+	UserID  string `json:"user_id"`
+	Setting string `json:"setting"`
+	Value   string `json:"value"`
+}
+
+type subscriber struct {
+	ch chan Event
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = make(map[string]map[*subscriber]struct{})
+)
+
+// Publish sends an event to the guild's subscribers. It never blocks;
+// subscribers that are behind miss the event.
+func Publish(guildID, eventType string, data interface{}) {
+	event := Event{Type: eventType, GuildID: guildID, Time: time.Now().UTC(), Data: data}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for sub := range subscribers[guildID] {
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
+
+// PublishConfigUpdate publishes a config.update event for a settings
+// change.
+func PublishConfigUpdate(guildID, userID, setting, value string) {
+	Publish(guildID, TypeConfigUpdate, ConfigData{UserID: userID, Setting: setting, Value: value})
+}
+
+// Subscribe returns a channel of the guild's events and a function that
+// ends the subscription and closes the channel.
+func Subscribe(guildID string) (<-chan Event, func()) {
+	sub := &subscriber{ch: make(chan Event, subscriberBuffer)}
+
+	mu.Lock()
+	if subscribers[guildID] == nil {
+		subscribers[guildID] = make(map[*subscriber]struct{})
+	}
+	subscribers[guildID][sub] = struct{}{}
+	mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			mu.Lock()
+			delete(subscribers[guildID], sub)
+			if len(subscribers[guildID]) == 0 {
+				delete(subscribers, guildID)
+			}
+			mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Package invites tracks guild invites so member joins can be attributed to
+// the invite, and the member, that brought them in.
+package invites
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"aware/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	db *sql.DB
+
+	// cache holds the last known invites per guild. Deleted invites stay
+	// cached until the next join, because an invite that reaches its max
+	// uses is deleted before the join event arrives.
+	cache      = make(map[string]map[string]*discordgo.Invite)
+	cacheMutex sync.Mutex
+
+	// guildLocks serialize fetching a guild's invites and comparing them
+	// with the cache, so an older fetch never replaces a newer one.
+	guildLocks      = make(map[string]*sync.Mutex)
+	guildLocksMutex sync.Mutex
+)
+
+func guildLock(guildID string) *sync.Mutex {
+	guildLocksMutex.Lock()
+	defer guildLocksMutex.Unlock()
+
+	lock, ok := guildLocks[guildID]
+	if !ok {
+		lock = &sync.Mutex{}
+		guildLocks[guildID] = lock
+	}
+	return lock
+}
+
+// Init stores the database handle and creates the invite joins table.
+func Init(database *sql.DB) {
+	db = database
+
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS invite_joins (
+            guild_id TEXT NOT NULL,
+            user_id TEXT NOT NULL,
+            inviter_id TEXT,
+            code TEXT,
+            joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            left_at TIMESTAMP,
+            PRIMARY KEY (guild_id, user_id)
+        )
+    `)
+	if err != nil {
+		fmt.Printf("Error creating invite_joins table: %v\n", err)
+	}
+}
+
+// InitEvents registers the invite tracking handlers.
+func InitEvents(s *discordgo.Session) {
+	s.Identify.Intents |= discordgo.IntentsGuildInvites | discordgo.IntentsGuildMembers
+
+	s.AddHandler(handleGuildCreate)
+	s.AddHandler(handleInviteCreate)
+	s.AddHandler(handleMemberAdd)
+	s.AddHandler(handleMemberRemove)
+}
+
+func cacheInvites(guildID string, invites []*discordgo.Invite) {
+	guildCache := make(map[string]*discordgo.Invite, len(invites))
+	for _, invite := range invites {
+		guildCache[invite.Code] = invite
+	}
+	cache[guildID] = guildCache
+}
+
+func handleGuildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	lock := guildLock(g.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	invites, err := s.GuildInvites(g.ID)
+	if err != nil {
+		// Missing Manage Server, joins just won't be attributed
+		return
+	}
+
+	cacheMutex.Lock()
+	cacheInvites(g.ID, invites)
+	cacheMutex.Unlock()
+}
+
+func handleInviteCreate(s *discordgo.Session, e *discordgo.InviteCreate) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if cache[e.GuildID] == nil {
+		cache[e.GuildID] = make(map[string]*discordgo.Invite)
+	}
+	cache[e.GuildID][e.Code] = e.Invite
+}
+
+// findUsedInvite compares the guild's current invites with the cache to
+// find the one whose use count went up, then refreshes the cache.
+func findUsedInvite(s *discordgo.Session, guildID string) *discordgo.Invite {
+	lock := guildLock(guildID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	invites, err := s.GuildInvites(guildID)
+	if err != nil {
+		return nil
+	}
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	previous := cache[guildID]
+	cacheInvites(guildID, invites)
+
+	for _, invite := range invites {
+		if cached, ok := previous[invite.Code]; ok && invite.Uses > cached.Uses {
+			return invite
+		} else if !ok && invite.Uses > 0 {
+			// Created and used before we saw it
+			return invite
+		}
+	}
+
+	// An invite that disappeared after one more use hit its limit
+	current := make(map[string]bool, len(invites))
+	for _, invite := range invites {
+		current[invite.Code] = true
+	}
+	for code, cached := range previous {
+		if current[code] {
+			continue
+		}
+		if max := cached.MaxUses; max > 0 && cached.Uses+1 >= max {
+			cached.Uses++
+			return cached
+		}
+	}
+
+	if guild, err := s.State.Guild(guildID); err == nil && guild.VanityURLCode != "" {
+		return &discordgo.Invite{Code: guild.VanityURLCode}
+	}
+	return nil
+}
+
+func handleMemberAdd(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+	invite := findUsedInvite(s, m.GuildID)
+
+	var inviterID, code interface{}
+	if invite != nil {
+		code = invite.Code
+		if invite.Inviter != nil {
+			inviterID = invite.Inviter.ID
+		}
+	}
+
+	_, err := db.Exec(`
+        INSERT INTO invite_joins (guild_id, user_id, inviter_id, code, joined_at, left_at)
+        VALUES (?, ?, ?, ?, ?, NULL)
+        ON CONFLICT(guild_id, user_id) DO UPDATE SET
+            inviter_id = excluded.inviter_id, code = excluded.code,
+            joined_at = excluded.joined_at, left_at = NULL`,
+		m.GuildID, m.User.ID, inviterID, code, time.Now().UTC().Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		fmt.Printf("Error recording invite join: %v\n", err)
+	}
+
+	logging.LogMemberJoin(s, m.Member, invite)
+}
+
+func handleMemberRemove(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+	_, err := db.Exec(`
+        UPDATE invite_joins SET left_at = ?
+        WHERE guild_id = ? AND user_id = ?`,
+		time.Now().UTC().Format("2006-01-02 15:04:05"), m.GuildID, m.User.ID,
+	)
+	if err != nil {
+		fmt.Printf("Error recording invite leave: %v\n", err)
+	}
+}
+
+// Stats counts the members a user has invited to a guild.
+type Stats struct {
+	Joins int
+	Left  int
+}
+
+// InviterStats returns how many members joined through a user's invites and
+// how many of them have since left.
+func InviterStats(guildID, userID string) (Stats, error) {
+	var stats Stats
+	err := db.QueryRow(`
+        SELECT COUNT(*), COUNT(left_at) FROM invite_joins
+        WHERE guild_id = ? AND inviter_id = ?`, guildID, userID).Scan(&stats.Joins, &stats.Left)
+	return stats, err
+}
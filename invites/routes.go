@@ -0,0 +1,53 @@
+package invites
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"aware/router"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// RegisterRoutes registers the invite commands with r.
+func RegisterRoutes(r *router.Router) {
+	r.Command(&router.Command{
+		Name:        "invites",
+		Description: "Show how many members a user has invited",
+		Usage:       "[@user]",
+		Handler:     invitesCommand,
+		Middleware:  []router.Middleware{router.GuildOnly(), router.Cooldown(3 * time.Second)},
+	})
+}
+
+func invitesCommand(ctx *router.Context) error {
+	userID := ctx.UserID
+	if len(ctx.Args) > 0 {
+		userID = strings.Trim(ctx.Args[0], "<@!>")
+	}
+
+	stats, err := InviterStats(ctx.GuildID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = ctx.Session.ChannelMessageSendEmbed(ctx.ChannelID, &discordgo.MessageEmbed{
+		Title:       "Invites",
+		Description: fmt.Sprintf("<@%s> has brought in **%d** members", userID, stats.Joins-stats.Left),
+		Color:       0x3498db,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Joins",
+				Value:  fmt.Sprintf("%d", stats.Joins),
+				Inline: true,
+			},
+			{
+				Name:   "Left",
+				Value:  fmt.Sprintf("%d", stats.Left),
+				Inline: true,
+			},
+		},
+	})
+	return err
+}
@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"sort"
+	"sync"
+)
+
+// Category identifies a kind of log event. Each category is routed to its
+// own destination and can be toggled independently.
+type Category string
+
+const (
+	CategoryBotGuilds  Category = "bot_guilds"
+	CategoryAntinuke   Category = "antinuke"
+	CategoryModeration Category = "moderation"
+	CategoryConfig     Category = "config"
+)
+
+// CategoryInfo describes a registered category.
+type CategoryInfo struct {
+	ID          Category
+	Name        string
+	Description string
+	// Global categories are not tied to a guild and are configured once
+	// for the whole bot, e.g. the bot joining or leaving a guild.
+	Global bool
+	// Default resolves the destination used when a guild hasn't configured
+	// one. It may be nil.
+	Default func(guildID string) Destination
+}
+
+var (
+	registry      = make(map[Category]*CategoryInfo)
+	registryMutex sync.RWMutex
+)
+
+func init() {
+	Register(CategoryInfo{
+		ID:          CategoryBotGuilds,
+		Name:        "Bot Guilds",
+		Description: "The bot joining or leaving a server",
+		Global:      true,
+	})
+	Register(CategoryInfo{
+		ID:          CategoryAntinuke,
+		Name:        "Anti-Nuke",
+		Description: "Anti-Nuke detections",
+	})
+	Register(CategoryInfo{
+		ID:          CategoryModeration,
+		Name:        "Moderation",
+		Description: "Punishments applied to members",
+	})
+	Register(CategoryInfo{
+		ID:          CategoryConfig,
+		Name:        "Config Changes",
+		Description: "Changes to the bot's settings for this server",
+	})
+}
+
+// Register adds a category to the registry, replacing any category with the
+// same ID.
+func Register(info CategoryInfo) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[info.ID] = &info
+}
+
+// SetDefault sets the destination resolver used for a category when a guild
+// hasn't configured one.
+func SetDefault(id Category, resolve func(guildID string) Destination) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if info, ok := registry[id]; ok {
+		info.Default = resolve
+	}
+}
+
+// Lookup returns a registered category.
+func Lookup(id Category) (CategoryInfo, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	info, ok := registry[id]
+	if !ok {
+		return CategoryInfo{}, false
+	}
+	return *info, true
+}
+
+// Categories returns every registered category sorted by ID.
+func Categories() []CategoryInfo {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	categories := make([]CategoryInfo, 0, len(registry))
+	for _, info := range registry {
+		categories = append(categories, *info)
+	}
+	sort.Slice(categories, func(a, b int) bool {
+		return categories[a].ID < categories[b].ID
+	})
+	return categories
+}
+
+// GuildCategories returns the categories a guild can configure.
+func GuildCategories() []CategoryInfo {
+	var categories []CategoryInfo
+	for _, info := range Categories() {
+		if !info.Global {
+			categories = append(categories, info)
+		}
+	}
+	return categories
+}
@@ -0,0 +1,274 @@
+package logging
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"aware/events"
+	"aware/router"
+	"aware/settings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// RegisterRoutes registers the log configuration commands with r.
+func RegisterRoutes(r *router.Router) {
+	admin := []router.Middleware{router.GuildOnly(), router.RequireAdmin(), router.Cooldown(3 * time.Second)}
+	// Changing where logs go, or turning them off, could hide a nuke, so
+	// it's left to the owner like the rest of antinuke
+	owner := []router.Middleware{router.GuildOnly(), router.RequireOwner(), router.Cooldown(3 * time.Second)}
+
+	r.Command(&router.Command{
+		Name:        "logs",
+		Description: "Show where each log category is sent",
+		Handler:     logsCommand,
+		Middleware:  admin,
+	})
+	r.Command(&router.Command{
+		Name:        "logs set",
+		Description: "Send a log category to a channel or webhook",
+		Usage:       "<category> <#channel|webhook URL>",
+		Handler:     logsSetCommand,
+		Middleware:  owner,
+	})
+	r.Command(&router.Command{
+		Name:        "logs enable",
+		Description: "Turn a log category on",
+		Usage:       "<category>",
+		Handler:     logsToggleCommand(true),
+		Middleware:  owner,
+	})
+	r.Command(&router.Command{
+		Name:        "logs disable",
+		Description: "Turn a log category off",
+		Usage:       "<category>",
+		Handler:     logsToggleCommand(false),
+		Middleware:  owner,
+	})
+	r.Command(&router.Command{
+		Name:        "logs store",
+		Description: "Store message content so edits and deletes can be logged",
+		Usage:       "<on|off>",
+		Handler:     logsStoreCommand,
+		Middleware:  admin,
+	})
+	r.Command(&router.Command{
+		Name:        "logs retention",
+		Description: "Set how many days stored messages are kept",
+		Usage:       "<days>",
+		Handler:     logsRetentionCommand,
+		Middleware:  admin,
+	})
+	r.Command(&router.Command{
+		Name:        "logs reset",
+		Description: "Restore a log category's default destination",
+		Usage:       "<category>",
+		Handler:     logsResetCommand,
+		Middleware:  owner,
+	})
+}
+
+func describeDestination(dest Destination) string {
+	switch {
+	case dest.WebhookURL != "":
+		return "Webhook"
+	case dest.ChannelID != "":
+		return fmt.Sprintf("<#%s>", dest.ChannelID)
+	default:
+		return "Not set"
+	}
+}
+
+func logsCommand(ctx *router.Context) error {
+	var fields []*discordgo.MessageEmbedField
+	for _, info := range GuildCategories() {
+		dest := Resolve(ctx.GuildID, info.ID)
+		status := "✅ Enabled"
+		if !dest.Enabled {
+			status = "❌ Disabled"
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("%s (`%s`)", info.Name, info.ID),
+			Value: fmt.Sprintf("%s\n%s\n%s", info.Description, describeDestination(dest), status),
+		})
+	}
+
+	guild := settings.Get(ctx.GuildID)
+	storage := "❌ Off"
+	if guild.StoreMessages {
+		storage = "✅ On"
+	}
+
+	_, err := ctx.Session.ChannelMessageSendEmbed(ctx.ChannelID, &discordgo.MessageEmbed{
+		Title:       "Log Destinations",
+		Description: fmt.Sprintf("**Message storage:** %s\n**Retention:** %d days", storage, guild.RetentionDays),
+		Color:       0x3498db,
+		Fields:      fields,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Use %slogs set <category> <#channel|webhook URL> to change a destination", settings.Prefix(ctx.GuildID)),
+		},
+	})
+	return err
+}
+
+// guildCategory parses a category the invoker is allowed to configure.
+// Global categories are left to the bot's owners.
+func guildCategory(ctx *router.Context, name string) (CategoryInfo, error) {
+	info, ok := Lookup(Category(strings.ToLower(name)))
+	if !ok || (info.Global && !router.IsBotOwner(ctx.UserID)) {
+		var names []string
+		for _, info := range GuildCategories() {
+			names = append(names, "`"+string(info.ID)+"`")
+		}
+		return CategoryInfo{}, router.Errorf("Unknown log category. Available: %s", strings.Join(names, ", "))
+	}
+	return info, nil
+}
+
+func logsSetCommand(ctx *router.Context) error {
+	if len(ctx.Args) != 2 {
+		return router.Errorf("Usage: `%slogs set <category> <#channel|webhook URL>`", settings.Prefix(ctx.GuildID))
+	}
+
+	info, err := guildCategory(ctx, ctx.Args[0])
+	if err != nil {
+		return err
+	}
+
+	s, target := ctx.Session, ctx.Args[1]
+	var description string
+	if webhookID, token, ok := ParseWebhookURL(target); ok {
+		// Don't leave the webhook token in chat, even if it's rejected
+		s.ChannelMessageDelete(ctx.ChannelID, ctx.Message.ID)
+
+		webhook, err := s.WebhookWithToken(webhookID, token)
+		if err != nil || webhook.GuildID != ctx.GuildID {
+			return router.Errorf("That webhook doesn't exist or belongs to another server")
+		}
+		if err := SetWebhook(ctx.GuildID, info.ID, target); err != nil {
+			return err
+		}
+		description = "a webhook"
+	} else {
+		channelID := strings.TrimSuffix(strings.TrimPrefix(target, "<#"), ">")
+		channel, err := s.State.Channel(channelID)
+		if err != nil {
+			channel, err = s.Channel(channelID)
+		}
+		if err != nil || channel.GuildID != ctx.GuildID {
+			return router.Errorf("That channel doesn't exist in this server")
+		}
+		if err := SetChannel(ctx.GuildID, info.ID, channel.ID); err != nil {
+			return err
+		}
+		description = fmt.Sprintf("<#%s>", channel.ID)
+	}
+
+	LogConfigChange(s, ctx.GuildID, ctx.UserID, info.Name+" logs", description)
+	events.PublishConfigUpdate(ctx.GuildID, ctx.UserID, info.Name+" logs", description)
+	_, err = s.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("%s logs will be sent to %s", info.Name, description))
+	return err
+}
+
+func logsToggleCommand(enabled bool) router.HandlerFunc {
+	verb, state := "disable", "disabled"
+	if enabled {
+		verb, state = "enable", "enabled"
+	}
+
+	return func(ctx *router.Context) error {
+		if len(ctx.Args) != 1 {
+			return router.Errorf("Usage: `%slogs %s <category>`", settings.Prefix(ctx.GuildID), verb)
+		}
+
+		info, err := guildCategory(ctx, ctx.Args[0])
+		if err != nil {
+			return err
+		}
+
+		// Log while config logs are still on, so turning them off is recorded
+		if !enabled {
+			LogConfigChange(ctx.Session, ctx.GuildID, ctx.UserID, info.Name+" logs", state)
+			events.PublishConfigUpdate(ctx.GuildID, ctx.UserID, info.Name+" logs", state)
+		}
+		if err := SetEnabled(ctx.GuildID, info.ID, enabled); err != nil {
+			return err
+		}
+		if enabled {
+			LogConfigChange(ctx.Session, ctx.GuildID, ctx.UserID, info.Name+" logs", state)
+			events.PublishConfigUpdate(ctx.GuildID, ctx.UserID, info.Name+" logs", state)
+		}
+
+		_, err = ctx.Session.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("%s logs %s", info.Name, state))
+		return err
+	}
+}
+
+func logsResetCommand(ctx *router.Context) error {
+	if len(ctx.Args) != 1 {
+		return router.Errorf("Usage: `%slogs reset <category>`", settings.Prefix(ctx.GuildID))
+	}
+
+	info, err := guildCategory(ctx, ctx.Args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := Reset(ctx.GuildID, info.ID); err != nil {
+		return err
+	}
+
+	LogConfigChange(ctx.Session, ctx.GuildID, ctx.UserID, info.Name+" logs", "reset to default")
+	events.PublishConfigUpdate(ctx.GuildID, ctx.UserID, info.Name+" logs", "reset to default")
+	_, err = ctx.Session.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("%s logs reset to their default destination", info.Name))
+	return err
+}
+
+func logsStoreCommand(ctx *router.Context) error {
+	if len(ctx.Args) != 1 || (ctx.Args[0] != "on" && ctx.Args[0] != "off") {
+		return router.Errorf("Usage: `%slogs store <on|off>`", settings.Prefix(ctx.GuildID))
+	}
+
+	enabled := ctx.Args[0] == "on"
+	if err := settings.SetStoreMessages(ctx.GuildID, enabled); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Message storage enabled, messages are kept for %d days", settings.Get(ctx.GuildID).RetentionDays)
+	if !enabled {
+		if err := DeleteGuildMessages(ctx.GuildID); err != nil {
+			return err
+		}
+		message = "Message storage disabled and stored messages deleted"
+	}
+
+	LogConfigChange(ctx.Session, ctx.GuildID, ctx.UserID, "Message storage", ctx.Args[0])
+	events.PublishConfigUpdate(ctx.GuildID, ctx.UserID, "Message storage", ctx.Args[0])
+	_, err := ctx.Session.ChannelMessageSend(ctx.ChannelID, message)
+	return err
+}
+
+func logsRetentionCommand(ctx *router.Context) error {
+	if len(ctx.Args) != 1 {
+		return router.Errorf("Usage: `%slogs retention <days>`", settings.Prefix(ctx.GuildID))
+	}
+
+	days, err := strconv.Atoi(ctx.Args[0])
+	if err != nil {
+		return router.Errorf("Retention must be a number of days")
+	}
+	if err := settings.ValidateRetention(days); err != nil {
+		return router.Errorf("%s", err.Error())
+	}
+
+	if err := settings.SetRetentionDays(ctx.GuildID, days); err != nil {
+		return err
+	}
+
+	LogConfigChange(ctx.Session, ctx.GuildID, ctx.UserID, "Message retention", fmt.Sprintf("%d days", days))
+	events.PublishConfigUpdate(ctx.GuildID, ctx.UserID, "Message retention", fmt.Sprintf("%d days", days))
+	_, err = ctx.Session.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("Stored messages will be kept for %d days", days))
+	return err
+}
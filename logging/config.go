@@ -1,28 +1,172 @@
 package logging
 
 import (
-    "sync"
+	"database/sql"
+	"fmt"
+	"sync"
 )
 
+// Destination is where a category's logs are sent. WebhookURL takes
+// precedence over ChannelID when both are set.
+type Destination struct {
+	ChannelID  string
+	WebhookURL string
+	Enabled    bool
+}
+
+// IsZero reports whether the destination has nowhere to send to.
+func (d Destination) IsZero() bool {
+	return d.ChannelID == "" && d.WebhookURL == ""
+}
+
 var (
-    isEnabled = true
-    mu        sync.RWMutex
+	db *sql.DB
+
+	destinationCache = make(map[string]*Destination)
+	cacheMutex       sync.RWMutex
 )
 
-func Enable() {
-    mu.Lock()
-    defer mu.Unlock()
-    isEnabled = true
+// Init stores the database handle and creates the log destinations and
+// messages tables.
+func Init(database *sql.DB) {
+	db = database
+
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS log_destinations (
+            guild_id TEXT NOT NULL,
+            category TEXT NOT NULL,
+            channel_id TEXT,
+            webhook_url TEXT,
+            enabled BOOLEAN DEFAULT true,
+            PRIMARY KEY (guild_id, category)
+        )
+    `)
+	if err != nil {
+		fmt.Printf("Error creating log_destinations table: %v\n", err)
+	}
+
+	createMessagesTable()
+}
+
+// scope returns the guild ID a category is stored under. Global categories
+// are stored with an empty guild ID.
+func scope(guildID string, id Category) string {
+	if info, ok := Lookup(id); ok && info.Global {
+		return ""
+	}
+	return guildID
+}
+
+func cacheKey(guildID string, id Category) string {
+	return guildID + ":" + string(id)
+}
+
+// Configured returns the destination stored for a category, or nil if the
+// guild hasn't configured it.
+func Configured(guildID string, id Category) (*Destination, error) {
+	guildID = scope(guildID, id)
+	key := cacheKey(guildID, id)
+
+	cacheMutex.RLock()
+	dest, ok := destinationCache[key]
+	cacheMutex.RUnlock()
+	if ok {
+		return dest, nil
+	}
+
+	var channelID, webhookURL sql.NullString
+	var enabled bool
+	err := db.QueryRow(`
+        SELECT channel_id, webhook_url, enabled FROM log_destinations
+        WHERE guild_id = ? AND category = ?`, guildID, string(id)).Scan(&channelID, &webhookURL, &enabled)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		dest = &Destination{ChannelID: channelID.String, WebhookURL: webhookURL.String, Enabled: enabled}
+	}
+
+	cacheMutex.Lock()
+	destinationCache[key] = dest
+	cacheMutex.Unlock()
+	return dest, nil
+}
+
+// Resolve returns where a category's logs should go for a guild, falling
+// back to the category's default destination.
+func Resolve(guildID string, id Category) Destination {
+	info, ok := Lookup(id)
+	if !ok {
+		return Destination{}
+	}
+
+	dest := Destination{Enabled: true}
+	stored, err := Configured(guildID, id)
+	if err != nil {
+		fmt.Printf("Error loading log destination %s for guild %s: %v\n", id, guildID, err)
+	} else if stored != nil {
+		dest = *stored
+	}
+
+	if dest.IsZero() && info.Default != nil {
+		fallback := info.Default(scope(guildID, id))
+		dest.ChannelID, dest.WebhookURL = fallback.ChannelID, fallback.WebhookURL
+	}
+	return dest
+}
+
+// Active reports whether a category's logs are sent anywhere for a guild.
+func Active(guildID string, id Category) bool {
+	dest := Resolve(guildID, id)
+	return dest.Enabled && !dest.IsZero()
+}
+
+func store(guildID string, id Category, query string, args ...interface{}) error {
+	if _, ok := Lookup(id); !ok {
+		return fmt.Errorf("unknown log category %q", id)
+	}
+	guildID = scope(guildID, id)
+
+	_, err := db.Exec(query, append([]interface{}{guildID, string(id)}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	cacheMutex.Lock()
+	delete(destinationCache, cacheKey(guildID, id))
+	cacheMutex.Unlock()
+	return nil
+}
+
+// SetChannel routes a category to a channel.
+func SetChannel(guildID string, id Category, channelID string) error {
+	return store(guildID, id, `
+        INSERT INTO log_destinations (guild_id, category, channel_id, webhook_url) VALUES (?, ?, ?, NULL)
+        ON CONFLICT(guild_id, category) DO UPDATE SET channel_id = excluded.channel_id, webhook_url = NULL`,
+		channelID,
+	)
+}
+
+// SetWebhook routes a category to a webhook URL.
+func SetWebhook(guildID string, id Category, webhookURL string) error {
+	return store(guildID, id, `
+        INSERT INTO log_destinations (guild_id, category, channel_id, webhook_url) VALUES (?, ?, NULL, ?)
+        ON CONFLICT(guild_id, category) DO UPDATE SET channel_id = NULL, webhook_url = excluded.webhook_url`,
+		webhookURL,
+	)
 }
 
-func Disable() {
-    mu.Lock()
-    defer mu.Unlock()
-    isEnabled = false
+// SetEnabled turns a category on or off without touching its destination.
+func SetEnabled(guildID string, id Category, enabled bool) error {
+	return store(guildID, id, `
+        INSERT INTO log_destinations (guild_id, category, enabled) VALUES (?, ?, ?)
+        ON CONFLICT(guild_id, category) DO UPDATE SET enabled = excluded.enabled`,
+		enabled,
+	)
 }
 
-func IsEnabled() bool {
-    mu.RLock()
-    defer mu.RUnlock()
-    return isEnabled
+// Reset removes a category's configuration so it falls back to its default
+// destination and is enabled again.
+func Reset(guildID string, id Category) error {
+	return store(guildID, id, `DELETE FROM log_destinations WHERE guild_id = ? AND category = ?`)
 }
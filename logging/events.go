@@ -0,0 +1,20 @@
+package logging
+
+import "github.com/bwmarrin/discordgo"
+
+// InitEvents registers the guild log handlers.
+func InitEvents(s *discordgo.Session) {
+	// Messages
+	s.AddHandler(StoreMessage)
+	s.AddHandler(LogMessageUpdate)
+	s.AddHandler(LogMessageDelete)
+	s.AddHandler(LogMessageDeleteBulk)
+
+	// Members, joins are logged by the invites package once the invite
+	// used is known
+	s.AddHandler(LogMemberLeave)
+	s.AddHandler(LogMemberUpdate)
+
+	// Voice
+	s.AddHandler(LogVoiceStateUpdate)
+}
@@ -6,12 +6,7 @@ import (
     "github.com/bwmarrin/discordgo"
 )
 
-const LogChannelID = "1341080255435247637"
-
 func LogGuildJoin(s *discordgo.Session, guild *discordgo.Guild) {
-	if !IsEnabled() {
-        return
-    }
     embed := &discordgo.MessageEmbed{
         Title: "Bot Joined New Guild",
         Color: 0x00ff00,
@@ -49,7 +44,7 @@ func LogGuildJoin(s *discordgo.Session, guild *discordgo.Guild) {
         }
     }
 
-    _, err := s.ChannelMessageSendEmbed(LogChannelID, embed)
+    err := Send(s, guild.ID, CategoryBotGuilds, embed)
     if err != nil {
         fmt.Printf("Error sending guild join log: %v\n", err)
     }
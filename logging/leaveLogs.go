@@ -7,10 +7,6 @@ import (
 )
 
 func LogGuildLeave(s *discordgo.Session, guild *discordgo.Guild) {
-    if !IsEnabled() {
-        return
-    }
-
     embed := &discordgo.MessageEmbed{
         Title: "Bot Left Guild",
         Color: 0xff0000,
@@ -53,7 +49,7 @@ func LogGuildLeave(s *discordgo.Session, guild *discordgo.Guild) {
         }
     }
 
-    _, err := s.ChannelMessageSendEmbed(LogChannelID, embed)
+    err := Send(s, guild.ID, CategoryBotGuilds, embed)
     if err != nil {
         fmt.Printf("Error sending guild leave log: %v\n", err)
     }
@@ -0,0 +1,222 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// CategoryMembers logs members joining, leaving and changing their profile.
+const CategoryMembers Category = "members"
+
+func init() {
+	Register(CategoryInfo{
+		ID:          CategoryMembers,
+		Name:        "Members",
+		Description: "Member joins, leaves, nicknames, roles, avatars and timeouts",
+	})
+}
+
+func memberEmbed(title string, color int, user *discordgo.User, fields ...*discordgo.MessageEmbedField) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title: title,
+		Color: color,
+		Fields: append([]*discordgo.MessageEmbedField{
+			{
+				Name:   "User",
+				Value:  fmt.Sprintf("<@%s>", user.ID),
+				Inline: true,
+			},
+			{
+				Name:   "User ID",
+				Value:  user.ID,
+				Inline: true,
+			},
+		}, fields...),
+		Thumbnail: &discordgo.MessageEmbedThumbnail{
+			URL: user.AvatarURL("128"),
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Member Log",
+		},
+	}
+}
+
+func sendMemberLog(s *discordgo.Session, guildID string, embed *discordgo.MessageEmbed) {
+	if err := Send(s, guildID, CategoryMembers, embed); err != nil {
+		fmt.Printf("Error sending member log: %v\n", err)
+	}
+}
+
+// accountAge formats how long ago a Discord account was created.
+func accountAge(user *discordgo.User) string {
+	created, err := discordgo.SnowflakeTimestamp(user.ID)
+	if err != nil {
+		return "Unknown"
+	}
+
+	age := fmt.Sprintf("<t:%d:R>", created.Unix())
+	if time.Since(created) < 7*24*time.Hour {
+		age += " ⚠️ New account"
+	}
+	return age
+}
+
+// auditActor returns who performed the most recent audit log action of the
+// given type against targetID, and why.
+func auditActor(s *discordgo.Session, guildID, targetID string, action discordgo.AuditLogAction) (string, string) {
+	auditLog, err := s.GuildAuditLog(guildID, "", "", int(action), 5)
+	if err != nil {
+		return "", ""
+	}
+	for _, entry := range auditLog.AuditLogEntries {
+		if entry.TargetID == targetID {
+			return entry.UserID, entry.Reason
+		}
+	}
+	return "", ""
+}
+
+// LogMemberJoin logs a member joining a guild. invite is the invite they
+// used, or nil if it couldn't be determined.
+func LogMemberJoin(s *discordgo.Session, member *discordgo.Member, invite *discordgo.Invite) {
+	inviteUsed := "Unknown"
+	if invite != nil {
+		inviteUsed = fmt.Sprintf("`%s`", invite.Code)
+		if invite.Inviter != nil {
+			inviteUsed += fmt.Sprintf(" by <@%s>", invite.Inviter.ID)
+		}
+	}
+
+	sendMemberLog(s, member.GuildID, memberEmbed("Member Joined", 0x00ff00, member.User,
+		&discordgo.MessageEmbedField{
+			Name:   "Account Created",
+			Value:  accountAge(member.User),
+			Inline: true,
+		},
+		&discordgo.MessageEmbedField{
+			Name:   "Invite Used",
+			Value:  inviteUsed,
+			Inline: true,
+		},
+	))
+}
+
+// LogMemberLeave logs a member leaving or being removed from a guild.
+func LogMemberLeave(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+	sendMemberLog(s, m.GuildID, memberEmbed("Member Left", 0xff0000, m.User,
+		&discordgo.MessageEmbedField{
+			Name:   "Account Created",
+			Value:  accountAge(m.User),
+			Inline: true,
+		},
+	))
+}
+
+func roleMentions(ids []string) string {
+	mentions := make([]string, len(ids))
+	for i, id := range ids {
+		mentions[i] = fmt.Sprintf("<@&%s>", id)
+	}
+	return strings.Join(mentions, " ")
+}
+
+// diffRoles returns the roles in after but not before, and the reverse.
+func diffRoles(before, after []string) (added, removed []string) {
+	had := make(map[string]bool, len(before))
+	for _, id := range before {
+		had[id] = true
+	}
+	for _, id := range after {
+		if !had[id] {
+			added = append(added, id)
+		}
+		delete(had, id)
+	}
+	for _, id := range before {
+		if had[id] {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
+
+func timedOut(member *discordgo.Member) bool {
+	return member.CommunicationDisabledUntil != nil && member.CommunicationDisabledUntil.After(time.Now())
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "*None*"
+	}
+	return value
+}
+
+// LogMemberUpdate logs nickname, role, avatar and timeout changes. It needs
+// the member's previous state from the state cache.
+func LogMemberUpdate(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+	before := m.BeforeUpdate
+	if before == nil || m.User == nil || m.User.Bot {
+		return
+	}
+
+	if before.Nick != m.Nick {
+		sendMemberLog(s, m.GuildID, memberEmbed("Nickname Changed", 0x3498db, m.User,
+			&discordgo.MessageEmbedField{Name: "Before", Value: valueOrNone(before.Nick)},
+			&discordgo.MessageEmbedField{Name: "After", Value: valueOrNone(m.Nick)},
+		))
+	}
+
+	if added, removed := diffRoles(before.Roles, m.Roles); len(added) > 0 || len(removed) > 0 {
+		var fields []*discordgo.MessageEmbedField
+		if len(added) > 0 {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: "Added", Value: roleMentions(added)})
+		}
+		if len(removed) > 0 {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: "Removed", Value: roleMentions(removed)})
+		}
+		if actor, _ := auditActor(s, m.GuildID, m.User.ID, discordgo.AuditLogActionMemberRoleUpdate); actor != "" {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: "Updated By", Value: fmt.Sprintf("<@%s>", actor)})
+		}
+		sendMemberLog(s, m.GuildID, memberEmbed("Roles Updated", 0x9b59b6, m.User, fields...))
+	}
+
+	if (before.User != nil && before.User.Avatar != m.User.Avatar) || before.Avatar != m.Avatar {
+		embed := memberEmbed("Avatar Changed", 0x1abc9c, m.User)
+		if before.User != nil {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:  "Before",
+				Value: fmt.Sprintf("[Link](%s)", before.AvatarURL("256")),
+			})
+		}
+		embed.Image = &discordgo.MessageEmbedImage{URL: m.AvatarURL("256")}
+		sendMemberLog(s, m.GuildID, embed)
+	}
+
+	if wasTimedOut, isTimedOut := timedOut(before), timedOut(m.Member); wasTimedOut != isTimedOut {
+		title, color := "Timeout Removed", 0x00ff00
+		var fields []*discordgo.MessageEmbedField
+		if isTimedOut {
+			title, color = "Member Timed Out", 0xe67e22
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name:   "Until",
+				Value:  fmt.Sprintf("<t:%d:f>", m.CommunicationDisabledUntil.Unix()),
+				Inline: true,
+			})
+		}
+		if actor, reason := auditActor(s, m.GuildID, m.User.ID, discordgo.AuditLogActionMemberUpdate); actor != "" {
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name:   "Moderator",
+				Value:  fmt.Sprintf("<@%s>", actor),
+				Inline: true,
+			})
+			if reason != "" {
+				fields = append(fields, &discordgo.MessageEmbedField{Name: "Reason", Value: reason})
+			}
+		}
+		sendMemberLog(s, m.GuildID, memberEmbed(title, color, m.User, fields...))
+	}
+}
@@ -0,0 +1,191 @@
+package logging
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// CategoryMessages logs edited and deleted messages.
+const CategoryMessages Category = "messages"
+
+// maxFieldLength is Discord's limit for an embed field value.
+const maxFieldLength = 1024
+
+func init() {
+	Register(CategoryInfo{
+		ID:          CategoryMessages,
+		Name:        "Messages",
+		Description: "Edited and deleted messages",
+	})
+}
+
+func truncate(content string, max int) string {
+	if content == "" {
+		return "*No content*"
+	}
+	if len(content) <= max {
+		return content
+	}
+	return content[:max-3] + "..."
+}
+
+func attachmentList(attachments []*discordgo.MessageAttachment) string {
+	var lines []string
+	for _, a := range attachments {
+		lines = append(lines, fmt.Sprintf("[%s](%s)", a.Filename, a.URL))
+	}
+	return truncate(strings.Join(lines, "\n"), maxFieldLength)
+}
+
+// LogMessageUpdate logs the before and after content of an edited message
+// and records the new content.
+func LogMessageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	if m.GuildID == "" || (m.Author != nil && m.Author.Bot) {
+		return
+	}
+
+	stored, err := loadMessage(m.ID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			fmt.Printf("Error loading edited message: %v\n", err)
+		}
+		return
+	}
+
+	// Embed unfurls and pins also fire updates without changing content
+	if stored.Content == m.Content {
+		return
+	}
+
+	markEdited(m.Message)
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Message Edited",
+		URL:         fmt.Sprintf("https://discord.com/channels/%s/%s/%s", m.GuildID, m.ChannelID, m.ID),
+		Description: fmt.Sprintf("**Author:** <@%s>\n**Channel:** <#%s>", stored.AuthorID, m.ChannelID),
+		Color:       0xf1c40f,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Before", Value: truncate(stored.Content, maxFieldLength)},
+			{Name: "After", Value: truncate(m.Content, maxFieldLength)},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Message ID: " + m.ID,
+		},
+	}
+	if len(m.Attachments) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Attachments",
+			Value: attachmentList(m.Attachments),
+		})
+	}
+
+	if err := Send(s, m.GuildID, CategoryMessages, embed); err != nil {
+		fmt.Printf("Error sending message edit log: %v\n", err)
+	}
+}
+
+// LogMessageDelete logs the stored content of a deleted message.
+func LogMessageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
+	before := m.BeforeDelete
+	if m.GuildID == "" || (before != nil && before.Author != nil && before.Author.Bot) {
+		return
+	}
+
+	stored, err := loadMessage(m.ID)
+	if err != nil && err != sql.ErrNoRows {
+		fmt.Printf("Error loading deleted message: %v\n", err)
+	}
+	if stored != nil {
+		markDeleted(m.ID)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:     "Message Deleted",
+		Color:     0xe74c3c,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Message ID: " + m.ID,
+		},
+	}
+
+	if stored != nil {
+		embed.Description = fmt.Sprintf("**Author:** <@%s>\n**Channel:** <#%s>", stored.AuthorID, m.ChannelID)
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Content",
+			Value: truncate(stored.Content, maxFieldLength),
+		})
+	} else {
+		embed.Description = fmt.Sprintf("**Channel:** <#%s>\n*Content was not stored*", m.ChannelID)
+	}
+
+	var attachments []*discordgo.MessageAttachment
+	if stored != nil {
+		attachments = stored.Attachments
+	} else if before != nil {
+		// The state cache still has attachments if it tracked the message
+		attachments = before.Attachments
+	}
+	if len(attachments) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Attachments",
+			Value: attachmentList(attachments),
+		})
+	}
+
+	if err := Send(s, m.GuildID, CategoryMessages, embed); err != nil {
+		fmt.Printf("Error sending message delete log: %v\n", err)
+	}
+}
+
+// LogMessageDeleteBulk logs a purge as a summary embed with every stored
+// message attached as a text file.
+func LogMessageDeleteBulk(s *discordgo.Session, m *discordgo.MessageDeleteBulk) {
+	if m.GuildID == "" {
+		return
+	}
+
+	var transcript bytes.Buffer
+	found := 0
+	for _, id := range m.Messages {
+		stored, err := loadMessage(id)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				fmt.Printf("Error loading bulk deleted message: %v\n", err)
+			}
+			fmt.Fprintf(&transcript, "[%s] (not stored)\n", id)
+			continue
+		}
+		found++
+		fmt.Fprintf(&transcript, "[%s] %s %s: %s\n", id, stored.CreatedAt, stored.AuthorID, stored.Content)
+		for _, a := range stored.Attachments {
+			fmt.Fprintf(&transcript, "    attachment: %s\n", a.URL)
+		}
+	}
+	markDeleted(m.Messages...)
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Messages Bulk Deleted",
+		Description: fmt.Sprintf("**Channel:** <#%s>\n**Messages:** %d\n**Stored:** %d", m.ChannelID, len(m.Messages), found),
+		Color:       0xe74c3c,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Full transcript attached",
+		},
+	}
+
+	files := []*discordgo.File{{
+		Name:        fmt.Sprintf("deleted-%s-%d.txt", m.ChannelID, time.Now().Unix()),
+		ContentType: "text/plain",
+		Reader:      &transcript,
+	}}
+
+	if err := SendFiles(s, m.GuildID, CategoryMessages, embed, files); err != nil {
+		fmt.Printf("Error sending bulk delete log: %v\n", err)
+	}
+}
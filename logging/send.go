@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ParseWebhookURL extracts the ID and token from a Discord webhook URL.
+func ParseWebhookURL(url string) (id, token string, ok bool) {
+	parts := strings.Split(strings.TrimSuffix(url, "/"), "/")
+	if len(parts) < 4 || parts[len(parts)-3] != "webhooks" {
+		return "", "", false
+	}
+	id, token = parts[len(parts)-2], parts[len(parts)-1]
+	return id, token, id != "" && token != ""
+}
+
+// Send delivers an embed to the destination configured for a category. It
+// does nothing when the category is disabled or has no destination.
+func Send(s *discordgo.Session, guildID string, id Category, embed *discordgo.MessageEmbed) error {
+	return SendFiles(s, guildID, id, embed, nil)
+}
+
+// SendFiles is like Send but also uploads files with the embed.
+func SendFiles(s *discordgo.Session, guildID string, id Category, embed *discordgo.MessageEmbed, files []*discordgo.File) error {
+	info, ok := Lookup(id)
+	if !ok {
+		return fmt.Errorf("unknown log category %q", id)
+	}
+
+	dest := Resolve(guildID, id)
+	if !dest.Enabled || dest.IsZero() {
+		return nil
+	}
+
+	if dest.WebhookURL != "" {
+		webhookID, token, ok := ParseWebhookURL(dest.WebhookURL)
+		if !ok {
+			return fmt.Errorf("invalid webhook URL for %s logs", info.Name)
+		}
+		_, err := s.WebhookExecute(webhookID, token, true, &discordgo.WebhookParams{
+			Embeds:   []*discordgo.MessageEmbed{embed},
+			Files:    files,
+			Username: info.Name + " Logs",
+		})
+		return err
+	}
+
+	_, err := s.ChannelMessageSendComplex(dest.ChannelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{embed},
+		Files:  files,
+	})
+	return err
+}
+
+// LogConfigChange records a change to a guild's bot settings. Callers
+// publish the change to the event stream themselves, since some changes
+// have their own event type.
+func LogConfigChange(s *discordgo.Session, guildID, userID, setting, value string) {
+	embed := &discordgo.MessageEmbed{
+		Title:       "Config Changed",
+		Description: fmt.Sprintf("**User:** <@%s>\n**Setting:** %s\n**Value:** %s", userID, setting, value),
+		Color:       0x3498db,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Aware Config Log",
+		},
+	}
+
+	if err := Send(s, guildID, CategoryConfig, embed); err != nil {
+		fmt.Printf("Error sending config change log: %v\n", err)
+	}
+}
@@ -0,0 +1,194 @@
+package logging
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"aware/schema"
+	"aware/settings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// sqliteTime matches SQLite's CURRENT_TIMESTAMP so stored times compare
+// correctly against datetime().
+const sqliteTime = "2006-01-02 15:04:05"
+
+// storedMessage is a message recorded for a guild that opted in to message
+// storage.
+type storedMessage struct {
+	GuildID     string
+	ChannelID   string
+	AuthorID    string
+	Content     string
+	Attachments []*discordgo.MessageAttachment
+	CreatedAt   string
+	EditedAt    string
+	DeletedAt   string
+}
+
+func createMessagesTable() {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS messages (
+            id TEXT PRIMARY KEY,
+            guild_id TEXT,
+            channel_id TEXT,
+            author_id TEXT,
+            content TEXT,
+            attachments TEXT,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            edited_at TIMESTAMP,
+            deleted_at TIMESTAMP
+        )
+    `)
+	if err != nil {
+		fmt.Printf("Error creating messages table: %v\n", err)
+		return
+	}
+
+	// Older databases stored only id, author_id, content and timestamp
+	columns, err := schema.Columns(db, "messages")
+	if err != nil {
+		fmt.Printf("Error reading messages columns: %v\n", err)
+		return
+	}
+	if columns["timestamp"] && !columns["created_at"] {
+		if _, err := db.Exec("ALTER TABLE messages RENAME COLUMN timestamp TO created_at"); err != nil {
+			fmt.Printf("Error renaming messages.timestamp: %v\n", err)
+		}
+	}
+	for _, column := range []string{"guild_id", "channel_id", "attachments", "created_at", "edited_at", "deleted_at"} {
+		if err := schema.AddColumnIfMissing(db, "messages", column, "TEXT"); err != nil {
+			fmt.Printf("Error migrating messages: %v\n", err)
+		}
+	}
+
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_messages_guild_created ON messages (guild_id, created_at)")
+	if err != nil {
+		fmt.Printf("Error creating messages index: %v\n", err)
+	}
+}
+
+func encodeAttachments(attachments []*discordgo.MessageAttachment) interface{} {
+	if len(attachments) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(attachments)
+	if err != nil {
+		return nil
+	}
+	return string(encoded)
+}
+
+// StoreMessage records a new message for guilds that opted in to message
+// storage.
+func StoreMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.GuildID == "" || m.Author == nil || m.Author.ID == s.State.User.ID {
+		return
+	}
+	if !settings.Get(m.GuildID).StoreMessages {
+		return
+	}
+
+	_, err := db.Exec(`
+        INSERT OR IGNORE INTO messages (id, guild_id, channel_id, author_id, content, attachments, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		m.ID, m.GuildID, m.ChannelID, m.Author.ID, m.Content, encodeAttachments(m.Attachments),
+		m.Timestamp.UTC().Format(sqliteTime),
+	)
+	if err != nil {
+		fmt.Printf("Error storing message: %v\n", err)
+	}
+}
+
+func loadMessage(messageID string) (*storedMessage, error) {
+	var msg storedMessage
+	var guildID, channelID, authorID, content, attachments, createdAt, editedAt, deletedAt sql.NullString
+	err := db.QueryRow(`
+        SELECT guild_id, channel_id, author_id, content, attachments, created_at, edited_at, deleted_at
+        FROM messages WHERE id = ?`, messageID).Scan(
+		&guildID, &channelID, &authorID, &content, &attachments, &createdAt, &editedAt, &deletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.GuildID, msg.ChannelID, msg.AuthorID = guildID.String, channelID.String, authorID.String
+	msg.Content, msg.CreatedAt = content.String, createdAt.String
+	msg.EditedAt, msg.DeletedAt = editedAt.String, deletedAt.String
+	if attachments.String != "" {
+		if err := json.Unmarshal([]byte(attachments.String), &msg.Attachments); err != nil {
+			fmt.Printf("Error decoding attachments for message %s: %v\n", messageID, err)
+		}
+	}
+	return &msg, nil
+}
+
+func markEdited(m *discordgo.Message) {
+	_, err := db.Exec(`
+        UPDATE messages SET content = ?, attachments = ?, edited_at = ?
+        WHERE id = ?`,
+		m.Content, encodeAttachments(m.Attachments), time.Now().UTC().Format(sqliteTime), m.ID,
+	)
+	if err != nil {
+		fmt.Printf("Error storing edited message: %v\n", err)
+	}
+}
+
+func markDeleted(messageIDs ...string) {
+	deletedAt := time.Now().UTC().Format(sqliteTime)
+	for _, id := range messageIDs {
+		if _, err := db.Exec("UPDATE messages SET deleted_at = ? WHERE id = ?", deletedAt, id); err != nil {
+			fmt.Printf("Error marking message %s deleted: %v\n", id, err)
+		}
+	}
+}
+
+// DeleteGuildMessages removes every stored message for a guild.
+func DeleteGuildMessages(guildID string) error {
+	_, err := db.Exec("DELETE FROM messages WHERE guild_id = ?", guildID)
+	return err
+}
+
+// PurgeMessages deletes messages older than their guild's retention period.
+// Messages without a guild use the default period.
+func PurgeMessages() (int64, error) {
+	result, err := db.Exec(`
+        DELETE FROM messages
+        WHERE created_at < datetime('now', '-' || COALESCE(
+            (SELECT message_retention_days FROM guild_settings g WHERE g.guild_id = messages.guild_id),
+            ?
+        ) || ' days')`,
+		settings.DefaultRetentionDays,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartMessagePurge runs PurgeMessages immediately and then on every
+// interval until the returned stop function is called.
+func StartMessagePurge(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if purged, err := PurgeMessages(); err != nil {
+				fmt.Printf("Error purging stored messages: %v\n", err)
+			} else if purged > 0 {
+				fmt.Printf("Purged %d stored messages past retention\n", purged)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,274 @@
+package logging
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// CategoryVoice logs voice channel activity.
+const CategoryVoice Category = "voice"
+
+const (
+	// freshEntryWindow is how old a newly seen audit log entry may be and
+	// still be attributed to the voice event being handled.
+	freshEntryWindow = 15 * time.Second
+	// auditDebounce is how long after an audit log lookup the voice events
+	// of the same guild only use the actions it found, so a burst of leaves
+	// makes one request instead of one each.
+	auditDebounce = 2 * time.Second
+)
+
+func init() {
+	Register(CategoryInfo{
+		ID:          CategoryVoice,
+		Name:        "Voice",
+		Description: "Voice joins, leaves, moves, server mutes/deafens and streams",
+	})
+}
+
+var (
+	// auditCounts remembers the count of every aggregated audit log entry
+	// seen per guild. Discord bumps the count of a recent entry instead of
+	// adding a new one when a moderator repeats a move or disconnect.
+	auditCounts = make(map[string]map[string]int)
+	// auditCredits holds the actions counted in the audit log that no
+	// voice event has claimed yet. One entry's count can jump by several
+	// at once, and each of the voice events it covers claims one.
+	auditCredits = make(map[string][]auditCredit)
+	// auditFetched is when each guild's audit log was last looked up.
+	auditFetched     = make(map[string]time.Time)
+	auditCountsMutex sync.Mutex
+
+	disconnectHooks      []disconnectHook
+	disconnectHooksMutex sync.RWMutex
+)
+
+type auditCredit struct {
+	actorID string
+	expires time.Time
+}
+
+type disconnectHook struct {
+	enabled func(guildID string) bool
+	fn      func(s *discordgo.Session, guildID, actorID, userID string)
+}
+
+// OnForcedDisconnect registers a function called whenever a member is
+// disconnected from voice by someone else, in guilds where enabled returns
+// true.
+func OnForcedDisconnect(enabled func(guildID string) bool, hook func(s *discordgo.Session, guildID, actorID, userID string)) {
+	disconnectHooksMutex.Lock()
+	defer disconnectHooksMutex.Unlock()
+	disconnectHooks = append(disconnectHooks, disconnectHook{enabled: enabled, fn: hook})
+}
+
+// watchingDisconnects reports whether any OnForcedDisconnect hook wants
+// the guild's disconnects.
+func watchingDisconnects(guildID string) bool {
+	disconnectHooksMutex.RLock()
+	defer disconnectHooksMutex.RUnlock()
+	for _, hook := range disconnectHooks {
+		if hook.enabled(guildID) {
+			return true
+		}
+	}
+	return false
+}
+
+// voiceActor returns who performed an aggregated voice audit log action
+// (move or disconnect) that happened since the last check, or "" if the
+// member acted on their own. When an entry's count grows by more than one,
+// the extra actions are kept for the voice events still being handled, so
+// each disconnect in a mass disconnect is attributed.
+func voiceActor(s *discordgo.Session, guildID string, action discordgo.AuditLogAction) string {
+	key := guildID + ":" + strconv.Itoa(int(action))
+
+	auditCountsMutex.Lock()
+	if time.Since(auditFetched[key]) < auditDebounce {
+		defer auditCountsMutex.Unlock()
+		return takeCredit(key, nil)
+	}
+	auditFetched[key] = time.Now()
+	auditCountsMutex.Unlock()
+
+	auditLog, err := s.GuildAuditLog(guildID, "", "", int(action), 5)
+	if err != nil {
+		return ""
+	}
+
+	auditCountsMutex.Lock()
+	defer auditCountsMutex.Unlock()
+
+	seen := auditCounts[key]
+	now := time.Now()
+	var found []auditCredit
+
+	current := make(map[string]int, len(auditLog.AuditLogEntries))
+	for _, entry := range auditLog.AuditLogEntries {
+		count := 1
+		if entry.Options != nil && entry.Options.Count != "" {
+			count, _ = strconv.Atoi(entry.Options.Count)
+		}
+
+		added := 0
+		if previous, known := seen[entry.ID]; known {
+			added = count - previous
+			// A handler that fetched earlier may get the lock later, so
+			// never go back to an older count
+			count = max(count, previous)
+		} else if created, err := discordgo.SnowflakeTimestamp(entry.ID); err == nil && time.Since(created) < freshEntryWindow {
+			added = count
+		}
+		current[entry.ID] = count
+
+		for i := 0; i < added; i++ {
+			found = append(found, auditCredit{actorID: entry.UserID, expires: now.Add(freshEntryWindow)})
+		}
+	}
+	auditCounts[key] = current
+	return takeCredit(key, found)
+}
+
+// takeCredit adds newly found actions to the key's unclaimed ones and
+// claims the oldest that hasn't expired. Callers must hold
+// auditCountsMutex.
+func takeCredit(key string, found []auditCredit) string {
+	now := time.Now()
+	var credits []auditCredit
+	for _, credit := range append(auditCredits[key], found...) {
+		if now.Before(credit.expires) {
+			credits = append(credits, credit)
+		}
+	}
+
+	actor := ""
+	if len(credits) > 0 {
+		actor, credits = credits[0].actorID, credits[1:]
+	}
+	auditCredits[key] = credits
+	return actor
+}
+
+func voiceEmbed(title string, color int, userID string, fields ...*discordgo.MessageEmbedField) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title: title,
+		Color: color,
+		Fields: append([]*discordgo.MessageEmbedField{
+			{
+				Name:   "User",
+				Value:  fmt.Sprintf("<@%s>", userID),
+				Inline: true,
+			},
+		}, fields...),
+		Timestamp: time.Now().Format(time.RFC3339),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Voice Log",
+		},
+	}
+}
+
+func channelField(name, channelID string) *discordgo.MessageEmbedField {
+	return &discordgo.MessageEmbedField{
+		Name:   name,
+		Value:  fmt.Sprintf("<#%s>", channelID),
+		Inline: true,
+	}
+}
+
+func actorField(name, actorID string) *discordgo.MessageEmbedField {
+	return &discordgo.MessageEmbedField{
+		Name:   name,
+		Value:  fmt.Sprintf("<@%s>", actorID),
+		Inline: true,
+	}
+}
+
+// LogVoiceStateUpdate logs voice joins, leaves, moves, server mutes and
+// deafens and stream starts, and notifies OnForcedDisconnect hooks.
+func LogVoiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	if v.GuildID == "" {
+		return
+	}
+
+	// Actors come from the audit log, so only look them up when someone
+	// will see them
+	logVoice := Active(v.GuildID, CategoryVoice)
+	if !logVoice && !watchingDisconnects(v.GuildID) {
+		return
+	}
+
+	before := v.BeforeUpdate
+	if before == nil {
+		before = &discordgo.VoiceState{}
+	}
+
+	var embeds []*discordgo.MessageEmbed
+	switch {
+	case before.ChannelID == "" && v.ChannelID != "":
+		embeds = append(embeds, voiceEmbed("Joined Voice", 0x00ff00, v.UserID, channelField("Channel", v.ChannelID)))
+
+	case before.ChannelID != "" && v.ChannelID == "":
+		embed := voiceEmbed("Left Voice", 0xff0000, v.UserID, channelField("Channel", before.ChannelID))
+		if actor := voiceActor(s, v.GuildID, discordgo.AuditLogActionMemberDisconnect); actor != "" {
+			embed.Title = "Disconnected From Voice"
+			embed.Fields = append(embed.Fields, actorField("Disconnected By", actor))
+
+			disconnectHooksMutex.RLock()
+			for _, hook := range disconnectHooks {
+				if hook.enabled(v.GuildID) {
+					hook.fn(s, v.GuildID, actor, v.UserID)
+				}
+			}
+			disconnectHooksMutex.RUnlock()
+		}
+		embeds = append(embeds, embed)
+
+	case before.ChannelID != v.ChannelID && logVoice:
+		embed := voiceEmbed("Switched Voice Channel", 0x3498db, v.UserID,
+			channelField("From", before.ChannelID),
+			channelField("To", v.ChannelID),
+		)
+		if actor := voiceActor(s, v.GuildID, discordgo.AuditLogActionMemberMove); actor != "" {
+			embed.Title = "Moved Between Voice Channels"
+			embed.Fields = append(embed.Fields, actorField("Moved By", actor))
+		}
+		embeds = append(embeds, embed)
+	}
+
+	if v.ChannelID != "" && before.ChannelID != "" && logVoice {
+		for _, change := range []struct {
+			was, is bool
+			on, off string
+		}{
+			{before.Mute, v.Mute, "Server Muted", "Server Unmuted"},
+			{before.Deaf, v.Deaf, "Server Deafened", "Server Undeafened"},
+		} {
+			if change.was == change.is {
+				continue
+			}
+			title, color := change.off, 0x00ff00
+			if change.is {
+				title, color = change.on, 0xe67e22
+			}
+			embed := voiceEmbed(title, color, v.UserID, channelField("Channel", v.ChannelID))
+			if actor, _ := auditActor(s, v.GuildID, v.UserID, discordgo.AuditLogActionMemberUpdate); actor != "" {
+				embed.Fields = append(embed.Fields, actorField("Moderator", actor))
+			}
+			embeds = append(embeds, embed)
+		}
+	}
+
+	if !before.SelfStream && v.SelfStream && v.ChannelID != "" {
+		embeds = append(embeds, voiceEmbed("Started Streaming", 0x9b59b6, v.UserID, channelField("Channel", v.ChannelID)))
+	}
+
+	for _, embed := range embeds {
+		if err := Send(s, v.GuildID, CategoryVoice, embed); err != nil {
+			fmt.Printf("Error sending voice log: %v\n", err)
+		}
+	}
+}
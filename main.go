@@ -3,20 +3,21 @@ package main
 import (
     "fmt"
     "log"
-    "time"
     "os"
     "os/signal"
-
-
-    "crypto/rand"
-    "encoding/base64"
-
     "syscall"
+    "time"
 
+    "aware/config"
     "aware/logging"
     "aware/antinuke"
+    "aware/dashboard"
+    "aware/invites"
+    "aware/router"
+    "aware/settings"
 
     "github.com/bwmarrin/discordgo"
+    "github.com/gorilla/mux"
 )
 
 func guildJoinHandler(s *discordgo.Session, g *discordgo.GuildCreate) {
@@ -27,72 +28,92 @@ func guildLeaveHandler(s *discordgo.Session, g *discordgo.GuildCreate) {
     logging.LogGuildLeave(s, g.Guild)
 }
 
-func generateSessionSecret() (string, error) {
-    b := make([]byte, 32)
-    _, err := rand.Read(b)
+func main() {
+    cfg, err := config.Load()
     if err != nil {
-        return "", err
+        log.Fatal(err)
     }
-    return base64.StdEncoding.EncodeToString(b), nil
-}
-
 
-func main() {
-
-    initDB()
+    initDB(cfg.DatabasePath)
     defer db.Close()
 
     antinuke.InitAntinuke(db)
+    settings.Init(db)
+    logging.Init(db)
+    invites.Init(db)
+    if cfg.LogChannelID != "" {
+        logging.SetDefault(logging.CategoryBotGuilds, func(string) logging.Destination {
+            return logging.Destination{ChannelID: cfg.LogChannelID}
+        })
+    }
 
-    dg, err := discordgo.New("Bot " + "")
+    router.SetBotOwners(cfg.OwnerIDs)
+    if cfg.ComponentSecret != "" {
+        router.SetSecret([]byte(cfg.ComponentSecret))
+    }
+
+    dg, err := discordgo.New("Bot " + cfg.Token)
     if err != nil {
         log.Fatal("Error creating Discord session: ", err)
     }
 
-    dg.AddHandler(antinuke.SetupCommand)
-    dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-        antinuke.HandleSetupButton(s, i)
-    })
+    r := router.New(settings.Prefix)
+    r.Use(router.Recover())
+    registerCommands(r)
+    antinuke.RegisterRoutes(r)
+    logging.RegisterRoutes(r)
+    invites.RegisterRoutes(r)
 
-    dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-        if i.Type == discordgo.InteractionModalSubmit && i.ModalSubmitData().CustomID == "limits_modal" {
-            antinuke.HandleLimitsModal(s, i)
-        }
-    })
+    dg.AddHandler(r.HandleMessage)
+    dg.AddHandler(r.HandleInteraction)
 
-    dg.Identify.Intents = discordgo.IntentsAll
+    dg.Identify.Intents = cfg.Intents
 
     antinuke.InitEvents(dg)
+    logging.InitEvents(dg)
+    invites.InitEvents(dg)
     dg.AddHandler(messageCreate)
     dg.AddHandler(guildJoinHandler)
 
-    dg.AddHandler(antinuke.WhitelistCommand)
-    dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-        antinuke.HandleWhitelistButton(s, i)
-    })
-    dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-        antinuke.HandleWhitelistSelect(s, i)
-    })
-
-
     dg.AddHandler(guildLeaveHandler)
 
+    // Set up the dashboard before connecting so a bad session setup stops
+    // startup early
+    httpRouter := mux.NewRouter()
+    if err := dashboard.Initialize(httpRouter, db, dg, cfg.Dashboard); err != nil {
+        log.Fatal("Error initializing dashboard: ", err)
+    }
+
     err = dg.Open()
     if err != nil {
         log.Fatal("Error opening connection: ", err)
     }
 
+    if err := antinuke.RegisterCommands(dg); err != nil {
+        log.Println("Error registering application commands: ", err)
+    }
+
     err = dg.UpdateGameStatus(0, "aware.wtf/discord")
     if err != nil {
         log.Println("Error setting status: ", err)
     }
 
+    stopPurge := logging.StartMessagePurge(time.Hour)
+    defer stopPurge()
+
+    stopSessionPurge := dashboard.StartSessionPurge(time.Hour)
+    defer stopSessionPurge()
+
+    srv := newHTTPServer(httpRouter, cfg.Port)
+    startHTTPServer(srv)
+
     fmt.Println("Bot is running. Press CTRL-C to exit.")
     sc := make(chan os.Signal, 1)
     signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
     <-sc
 
-    generateSessionSecret()
+    fmt.Println("Shutting down...")
+    stopHTTPServer(srv, cfg.ShutdownTimeout)
 
     dg.Close()
 }
@@ -102,33 +123,7 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
         return
     }
 
-    _, err := db.Exec(`
-        INSERT INTO messages (id, author_id, content) 
-        VALUES (?, ?, ?)
-    `, m.ID, m.Author.ID, m.Content)
-    
-    if err != nil {
-        log.Printf("Error storing message: %v", err)
-        return
-    }
-
     if m.Content == "/protected" {
         s.ChannelMessageSend(m.ChannelID, "Protection mode is active! 🛡️")
     }
-
-    if m.Content == ",help" {
-        helpMessage := fmt.Sprintf("<@%s> https://aware.wtf/help", m.Author.ID)
-        s.ChannelMessageSend(m.ChannelID, helpMessage)
-    }
-
-    if m.Content == ",ping" {
-        msgTime := m.Timestamp
-        
-        latency := time.Since(msgTime).Milliseconds()
-        wsLatency := s.HeartbeatLatency().Milliseconds()
-        
-        pingMessage := fmt.Sprintf("🏓 Pong!\n⏱️ API Latency: %dms\n📡 WebSocket: %dms", latency, wsLatency)
-        s.ChannelMessageSend(m.ChannelID, pingMessage)
-    }
-    
 }
\ No newline at end of file
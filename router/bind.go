@@ -0,0 +1,207 @@
+package router
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ComponentTTL is how long bound components stay usable.
+const ComponentTTL = 15 * time.Minute
+
+// MaxCustomIDLength is the longest custom ID Discord accepts.
+const MaxCustomIDLength = 100
+
+// bindSeparator splits a custom ID from its binding. Route arguments use
+// ":" so the two never clash.
+const bindSeparator = "|"
+
+var (
+	secretMu sync.RWMutex
+	secret   []byte
+)
+
+func init() {
+	// Random until SetSecret is called, so bound components don't survive
+	// a restart unless a persistent secret is configured.
+	secret = make([]byte, 32)
+	rand.Read(secret)
+}
+
+// SetSecret sets the key used to sign custom IDs.
+func SetSecret(key []byte) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	secret = key
+}
+
+// binding is the invoker, guild and expiry encoded into a custom ID.
+type binding struct {
+	UserID  string
+	GuildID string
+	Expires time.Time
+}
+
+func compactID(id string) string {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return "0"
+	}
+	return strconv.FormatUint(n, 36)
+}
+
+func expandID(id string) string {
+	n, err := strconv.ParseUint(id, 36, 64)
+	if err != nil || n == 0 {
+		return ""
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+func sign(payload string) string {
+	secretMu.RLock()
+	mac := hmac.New(sha256.New, secret)
+	secretMu.RUnlock()
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// Bind returns customID signed for userID in guildID, valid for ttl. The
+// binding adds up to about 50 characters, and Bind panics if the result
+// is longer than Discord allows.
+func Bind(customID, userID, guildID string, ttl time.Duration) string {
+	payload := strings.Join([]string{
+		customID,
+		compactID(userID),
+		compactID(guildID),
+		strconv.FormatInt(time.Now().Add(ttl).Unix(), 36),
+	}, bindSeparator)
+
+	bound := payload + bindSeparator + sign(payload)
+	if len(bound) > MaxCustomIDLength {
+		panic(fmt.Sprintf("router: bound custom ID for %q is %d characters, Discord allows %d",
+			customID, len(bound), MaxCustomIDLength))
+	}
+	return bound
+}
+
+// BaseID strips the binding from a custom ID.
+func BaseID(customID string) string {
+	if i := strings.Index(customID, bindSeparator); i >= 0 {
+		return customID[:i]
+	}
+	return customID
+}
+
+// parseBinding verifies a bound custom ID and returns its base ID and
+// binding. ok is false for unbound or forged IDs.
+func parseBinding(customID string) (string, *binding, bool) {
+	parts := strings.Split(customID, bindSeparator)
+	if len(parts) != 5 {
+		return customID, nil, false
+	}
+
+	payload := strings.Join(parts[:4], bindSeparator)
+	if !hmac.Equal([]byte(sign(payload)), []byte(parts[4])) {
+		return parts[0], nil, false
+	}
+
+	expires, err := strconv.ParseInt(parts[3], 36, 64)
+	if err != nil {
+		return parts[0], nil, false
+	}
+
+	return parts[0], &binding{
+		UserID:  expandID(parts[1]),
+		GuildID: expandID(parts[2]),
+		Expires: time.Unix(expires, 0),
+	}, true
+}
+
+// BindComponents signs every custom ID in components for userID.
+func BindComponents(components []discordgo.MessageComponent, userID, guildID string) []discordgo.MessageComponent {
+	return mapComponents(components, func(id string, disabled bool) (string, bool) {
+		return Bind(id, userID, guildID, ComponentTTL), disabled
+	})
+}
+
+// DisableComponents returns a copy of components with every button and
+// select menu disabled.
+func DisableComponents(components []discordgo.MessageComponent) []discordgo.MessageComponent {
+	return mapComponents(components, func(id string, _ bool) (string, bool) {
+		return id, true
+	})
+}
+
+// DisableComponent returns a copy of components with the component whose
+// base custom ID is customID disabled.
+func DisableComponent(components []discordgo.MessageComponent, customID string) []discordgo.MessageComponent {
+	return mapComponents(components, func(id string, disabled bool) (string, bool) {
+		return id, disabled || (id != "" && BaseID(id) == customID)
+	})
+}
+
+// mapComponents rewrites the custom ID and disabled state of buttons and
+// select menus, accepting both value and pointer components.
+func mapComponents(components []discordgo.MessageComponent, f func(string, bool) (string, bool)) []discordgo.MessageComponent {
+	out := make([]discordgo.MessageComponent, 0, len(components))
+	for _, c := range components {
+		switch v := c.(type) {
+		case discordgo.ActionsRow:
+			out = append(out, discordgo.ActionsRow{Components: mapComponents(v.Components, f)})
+		case *discordgo.ActionsRow:
+			out = append(out, discordgo.ActionsRow{Components: mapComponents(v.Components, f)})
+		case discordgo.Button:
+			out = append(out, mapButton(v, f))
+		case *discordgo.Button:
+			out = append(out, mapButton(*v, f))
+		case discordgo.SelectMenu:
+			out = append(out, mapSelectMenu(v, f))
+		case *discordgo.SelectMenu:
+			out = append(out, mapSelectMenu(*v, f))
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func mapButton(b discordgo.Button, f func(string, bool) (string, bool)) discordgo.Button {
+	// Link buttons have no custom ID
+	if b.CustomID != "" {
+		b.CustomID, b.Disabled = f(b.CustomID, b.Disabled)
+	} else {
+		_, b.Disabled = f("", b.Disabled)
+	}
+	return b
+}
+
+func mapSelectMenu(m discordgo.SelectMenu, f func(string, bool) (string, bool)) discordgo.SelectMenu {
+	m.CustomID, m.Disabled = f(m.CustomID, m.Disabled)
+	return m
+}
+
+// ExpireMessage disables the components of a channel message once they
+// expire. Presses after a restart are still rejected by the router.
+func ExpireMessage(s *discordgo.Session, m *discordgo.Message) {
+	if m == nil {
+		return
+	}
+
+	time.AfterFunc(ComponentTTL, func() {
+		components := DisableComponents(m.Components)
+		s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			Channel:    m.ChannelID,
+			ID:         m.ID,
+			Components: &components,
+		})
+	})
+}
@@ -0,0 +1,100 @@
+package router
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testUserID  = "123456789012345678"
+	testGuildID = "876543210987654321"
+)
+
+func TestBindRoundTrip(t *testing.T) {
+	id := Bind("whitelist_confirm_add:"+testUserID, testUserID, testGuildID, ComponentTTL)
+
+	base, b, ok := parseBinding(id)
+	if !ok {
+		t.Fatalf("parseBinding(%q) rejected its own binding", id)
+	}
+	if base != "whitelist_confirm_add:"+testUserID || BaseID(id) != base {
+		t.Errorf("base ID = %q, BaseID() = %q", base, BaseID(id))
+	}
+	if b.UserID != testUserID || b.GuildID != testGuildID {
+		t.Errorf("binding = %+v, want user %s in guild %s", b, testUserID, testGuildID)
+	}
+	if until := time.Until(b.Expires); until <= 0 || until > ComponentTTL {
+		t.Errorf("binding expires in %v, want within %v", until, ComponentTTL)
+	}
+}
+
+func TestBindRejectsTampering(t *testing.T) {
+	id := Bind("limits_modal", testUserID, testGuildID, ComponentTTL)
+	parts := strings.Split(id, bindSeparator)
+
+	tamper := func(i int, value string) string {
+		changed := append([]string(nil), parts...)
+		changed[i] = value
+		return strings.Join(changed, bindSeparator)
+	}
+	later := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 36)
+
+	tests := map[string]string{
+		"base ID":   tamper(0, "cancel_whitelist"),
+		"user":      tamper(1, compactID("223456789012345678")),
+		"guild":     tamper(2, compactID("976543210987654321")),
+		"expiry":    tamper(3, later),
+		"signature": tamper(4, strings.Repeat("A", len(parts[4]))),
+		"truncated": strings.Join(parts[:4], bindSeparator),
+		"extended":  id + bindSeparator + "x",
+		"unbound":   "limits_modal",
+	}
+	for name, forged := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, ok := parseBinding(forged); ok {
+				t.Errorf("parseBinding(%q) accepted a forged ID", forged)
+			}
+		})
+	}
+
+	t.Run("other secret", func(t *testing.T) {
+		secretMu.RLock()
+		prev := secret
+		secretMu.RUnlock()
+		defer SetSecret(prev)
+
+		SetSecret([]byte(strings.Repeat("x", 32)))
+		if _, _, ok := parseBinding(id); ok {
+			t.Error("parseBinding() accepted an ID signed with another secret")
+		}
+	})
+}
+
+func TestBindExpiry(t *testing.T) {
+	_, b, ok := parseBinding(Bind("limits_modal", testUserID, testGuildID, -time.Second))
+	if !ok {
+		t.Fatal("parseBinding() rejected an expired but genuine ID")
+	}
+	// The router rejects presses after Expires, see checkBinding
+	if !time.Now().After(b.Expires) {
+		t.Errorf("binding expires at %v, want it in the past", b.Expires)
+	}
+}
+
+func TestBindLength(t *testing.T) {
+	// The largest snowflakes make the longest binding
+	const maxID = "18446744073709551615"
+	id := Bind("whitelist_confirm_remove:"+maxID, maxID, maxID, ComponentTTL)
+	if len(id) > MaxCustomIDLength {
+		t.Errorf("len(%q) = %d, want at most %d", id, len(id), MaxCustomIDLength)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Bind() didn't panic on a custom ID over Discord's limit")
+		}
+	}()
+	Bind(strings.Repeat("x", MaxCustomIDLength), testUserID, testGuildID, ComponentTTL)
+}
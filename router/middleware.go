@@ -0,0 +1,122 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Recover turns a panic in a handler into an error.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) (err error) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Printf("Panic handling %s: %v\n%s", ctx.Route, rec, debug.Stack())
+					err = fmt.Errorf("panic: %v", rec)
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}
+
+// GuildOnly rejects events outside of a guild.
+func GuildOnly() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			if ctx.GuildID == "" {
+				return Errorf("This command can only be used in a server.")
+			}
+			return next(ctx)
+		}
+	}
+}
+
+func guild(ctx *Context) (*discordgo.Guild, error) {
+	if g, err := ctx.Session.State.Guild(ctx.GuildID); err == nil {
+		return g, nil
+	}
+	return ctx.Session.Guild(ctx.GuildID)
+}
+
+// RequireOwner only lets the guild owner through.
+func RequireOwner() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			g, err := guild(ctx)
+			if err != nil {
+				return Errorf("Error fetching guild information.")
+			}
+			if ctx.UserID != g.OwnerID {
+				return Errorf("Only the server owner can use this command!")
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// RequireAdmin lets the guild owner and members with Administrator through.
+func RequireAdmin() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			g, err := guild(ctx)
+			if err != nil {
+				return Errorf("Error fetching guild information.")
+			}
+			if ctx.UserID == g.OwnerID {
+				return next(ctx)
+			}
+
+			member, err := ctx.Session.State.Member(ctx.GuildID, ctx.UserID)
+			if err != nil {
+				if member, err = ctx.Session.GuildMember(ctx.GuildID, ctx.UserID); err != nil {
+					return Errorf("Error fetching member information.")
+				}
+			}
+
+			for _, role := range g.Roles {
+				for _, roleID := range member.Roles {
+					if roleID == role.ID && role.Permissions&discordgo.PermissionAdministrator != 0 {
+						return next(ctx)
+					}
+				}
+			}
+			return Errorf("Only server administrators can use this command!")
+		}
+	}
+}
+
+// Cooldown limits each user to one invocation of a route per period.
+func Cooldown(period time.Duration) Middleware {
+	var mu sync.Mutex
+	last := make(map[string]time.Time)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			key := ctx.Route + ":" + ctx.UserID
+			now := time.Now()
+
+			mu.Lock()
+			if t, ok := last[key]; ok && now.Sub(t) < period {
+				mu.Unlock()
+				remaining := period - now.Sub(t)
+				return Errorf("Slow down! Try again in %.1fs.", remaining.Seconds())
+			}
+			last[key] = now
+			// Drop stale entries so the map doesn't grow forever
+			for k, t := range last {
+				if now.Sub(t) >= period {
+					delete(last, k)
+				}
+			}
+			mu.Unlock()
+
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package router
+
+import "sync"
+
+var (
+	ownersMu sync.RWMutex
+	owners   = make(map[string]bool)
+)
+
+// SetBotOwners sets the users who own the bot itself, as opposed to a
+// guild. They can change bot-wide settings from any guild.
+func SetBotOwners(ids []string) {
+	ownersMu.Lock()
+	defer ownersMu.Unlock()
+
+	owners = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		owners[id] = true
+	}
+}
+
+// IsBotOwner reports whether userID is one of the bot's owners.
+func IsBotOwner(userID string) bool {
+	ownersMu.RLock()
+	defer ownersMu.RUnlock()
+	return owners[userID]
+}
@@ -0,0 +1,408 @@
+// Package router dispatches prefix commands, slash commands and component
+// interactions to handlers, with middleware shared between all of them.
+package router
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Context carries the event a handler is invoked for. Exactly one of
+// Message and Interaction is set.
+type Context struct {
+	Session     *discordgo.Session
+	Message     *discordgo.MessageCreate
+	Interaction *discordgo.InteractionCreate
+
+	// Route is the command name or custom ID prefix that matched.
+	Route string
+	// Args holds the words following a prefix command, or the part of a
+	// custom ID after the matched prefix split on ":".
+	Args []string
+
+	GuildID   string
+	ChannelID string
+	UserID    string
+}
+
+// HandlerFunc handles a routed event. Returned errors are rendered as an
+// embed to the invoking user.
+type HandlerFunc func(ctx *Context) error
+
+// Middleware wraps a handler.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Command is a prefix command such as `,antinuke setup`.
+type Command struct {
+	// Name may contain spaces for subcommands, e.g. "antinuke setup".
+	Name        string
+	Description string
+	Usage       string
+	Handler     HandlerFunc
+	Middleware  []Middleware
+	// Hidden commands are left out of the generated help.
+	Hidden bool
+}
+
+type route struct {
+	name    string
+	handler HandlerFunc
+}
+
+// PrefixFunc returns the text command prefix for a guild.
+type PrefixFunc func(guildID string) string
+
+// Router holds every registered route.
+type Router struct {
+	mu         sync.RWMutex
+	prefix     PrefixFunc
+	middleware []Middleware
+
+	commands   map[string]*Command
+	slash      map[string]HandlerFunc
+	components []route
+	modals     []route
+}
+
+// New returns a router for text commands starting with the guild's prefix
+// or a mention of the bot.
+func New(prefix PrefixFunc) *Router {
+	return &Router{
+		prefix:   prefix,
+		commands: make(map[string]*Command),
+		slash:    make(map[string]HandlerFunc),
+	}
+}
+
+// Use adds middleware run for every route, outermost first.
+func (r *Router) Use(mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Command registers a prefix command.
+func (r *Router) Command(cmd *Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cmd.Handler = chain(cmd.Handler, cmd.Middleware)
+	r.commands[cmd.Name] = cmd
+}
+
+// Slash registers a handler for the application command with the given name.
+func (r *Router) Slash(name string, h HandlerFunc, mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.slash[name] = chain(h, mw)
+}
+
+// Component registers a handler for message components whose custom ID is
+// prefix itself or starts with prefix followed by ":".
+func (r *Router) Component(prefix string, h HandlerFunc, mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.components = append(r.components, route{prefix, chain(h, mw)})
+}
+
+// Modal registers a handler for modal submissions, matched like Component.
+func (r *Router) Modal(prefix string, h HandlerFunc, mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.modals = append(r.modals, route{prefix, chain(h, mw)})
+}
+
+// Commands returns the visible prefix commands sorted by name.
+func (r *Router) Commands() []*Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var cmds []*Command
+	for _, cmd := range r.commands {
+		if !cmd.Hidden {
+			cmds = append(cmds, cmd)
+		}
+	}
+	sort.Slice(cmds, func(a, b int) bool { return cmds[a].Name < cmds[b].Name })
+	return cmds
+}
+
+// Prefix returns the prefix used for text commands in a guild.
+func (r *Router) Prefix(guildID string) string {
+	return r.prefix(guildID)
+}
+
+// stripPrefix removes the guild prefix or a leading bot mention.
+func (r *Router) stripPrefix(s *discordgo.Session, m *discordgo.MessageCreate) (string, bool) {
+	if s.State.User != nil {
+		for _, mention := range []string{"<@" + s.State.User.ID + ">", "<@!" + s.State.User.ID + ">"} {
+			if strings.HasPrefix(m.Content, mention) {
+				return strings.TrimPrefix(m.Content, mention), true
+			}
+		}
+	}
+
+	prefix := r.prefix(m.GuildID)
+	if !strings.HasPrefix(m.Content, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(m.Content, prefix), true
+}
+
+func chain(h HandlerFunc, mw []Middleware) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+func (r *Router) run(ctx *Context, h HandlerFunc) {
+	r.mu.RLock()
+	h = chain(h, r.middleware)
+	r.mu.RUnlock()
+
+	if err := h(ctx); err != nil {
+		renderError(ctx, err)
+	}
+}
+
+// HandleMessage is a discordgo handler for prefix commands.
+func (r *Router) HandleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot {
+		return
+	}
+
+	content, ok := r.stripPrefix(s, m)
+	if !ok {
+		return
+	}
+
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return
+	}
+
+	r.mu.RLock()
+	var cmd *Command
+	var args []string
+	// Longest match first so "antinuke setup" wins over "antinuke"
+	for n := len(fields); n > 0; n-- {
+		if c, ok := r.commands[strings.ToLower(strings.Join(fields[:n], " "))]; ok {
+			cmd, args = c, fields[n:]
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	if cmd == nil {
+		return
+	}
+
+	r.run(&Context{
+		Session:   s,
+		Message:   m,
+		Route:     cmd.Name,
+		Args:      args,
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		UserID:    m.Author.ID,
+	}, cmd.Handler)
+}
+
+// HandleInteraction is a discordgo handler for slash commands, components
+// and modal submissions.
+func (r *Router) HandleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	ctx := &Context{
+		Session:     s,
+		Interaction: i,
+		GuildID:     i.GuildID,
+		ChannelID:   i.ChannelID,
+	}
+	if i.Member != nil && i.Member.User != nil {
+		ctx.UserID = i.Member.User.ID
+	} else if i.User != nil {
+		ctx.UserID = i.User.ID
+	}
+
+	var h HandlerFunc
+	var b *binding
+	var bound bool
+
+	// Components and modals carry a signed binding which is stripped from
+	// the custom ID before handlers see it.
+	r.mu.RLock()
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		ctx.Route = i.ApplicationCommandData().Name
+		h = r.slash[ctx.Route]
+	case discordgo.InteractionMessageComponent:
+		data := i.MessageComponentData()
+		data.CustomID, b, bound = parseBinding(data.CustomID)
+		i.Data = data
+		h = matchRoute(ctx, r.components, data.CustomID)
+	case discordgo.InteractionModalSubmit:
+		data := i.ModalSubmitData()
+		data.CustomID, b, bound = parseBinding(data.CustomID)
+		i.Data = data
+		h = matchRoute(ctx, r.modals, data.CustomID)
+	}
+	r.mu.RUnlock()
+
+	if h == nil {
+		return
+	}
+
+	if i.Type != discordgo.InteractionApplicationCommand && !checkBinding(ctx, b, bound) {
+		return
+	}
+	r.run(ctx, h)
+}
+
+// checkBinding rejects component presses that aren't from the user the
+// component was bound to, or that have expired.
+func checkBinding(ctx *Context, b *binding, bound bool) bool {
+	s, i := ctx.Session, ctx.Interaction
+
+	if !bound || b.GuildID != ctx.GuildID {
+		respondEphemeral(s, i, "This panel is no longer valid, please run the command again.")
+		return false
+	}
+
+	if time.Now().After(b.Expires) {
+		if i.Type == discordgo.InteractionMessageComponent && i.Message != nil {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseUpdateMessage,
+				Data: &discordgo.InteractionResponseData{
+					Components: DisableComponents(i.Message.Components),
+				},
+			})
+			s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+				Content: "This panel has expired, please run the command again.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			})
+			return false
+		}
+		respondEphemeral(s, i, "This panel has expired, please run the command again.")
+		return false
+	}
+
+	if b.UserID != ctx.UserID {
+		respondEphemeral(s, i, fmt.Sprintf("Only <@%s> can use this panel.", b.UserID))
+		return false
+	}
+	return true
+}
+
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func matchRoute(ctx *Context, routes []route, customID string) HandlerFunc {
+	for _, rt := range routes {
+		if customID == rt.name {
+			ctx.Route = rt.name
+			return rt.handler
+		}
+		if strings.HasPrefix(customID, rt.name+":") {
+			ctx.Route = rt.name
+			ctx.Args = strings.Split(strings.TrimPrefix(customID, rt.name+":"), ":")
+			return rt.handler
+		}
+	}
+	return nil
+}
+
+// UserError is shown to the user verbatim. Any other error is logged and
+// replaced by a generic message.
+type UserError struct {
+	Message string
+}
+
+func (e *UserError) Error() string {
+	return e.Message
+}
+
+// Errorf returns a UserError with a formatted message.
+func Errorf(format string, a ...interface{}) error {
+	return &UserError{Message: fmt.Sprintf(format, a...)}
+}
+
+func renderError(ctx *Context, err error) {
+	message := "Something went wrong while running this command."
+	if userErr, ok := err.(*UserError); ok {
+		message = userErr.Message
+	} else {
+		log.Printf("Error handling %s: %v", ctx.Route, err)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Error",
+		Description: message,
+		Color:       0xff0000,
+	}
+
+	if ctx.Message != nil {
+		ctx.Session.ChannelMessageSendEmbed(ctx.ChannelID, embed)
+		return
+	}
+
+	err = ctx.Session.InteractionRespond(ctx.Interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		// The handler already responded, fall back to a followup
+		ctx.Session.FollowupMessageCreate(ctx.Interaction.Interaction, true, &discordgo.WebhookParams{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		})
+	}
+}
+
+// Interaction adapts a plain discordgo interaction handler.
+func Interaction(h func(s *discordgo.Session, i *discordgo.InteractionCreate)) HandlerFunc {
+	return func(ctx *Context) error {
+		h(ctx.Session, ctx.Interaction)
+		return nil
+	}
+}
+
+// HelpEmbed lists every visible prefix command with the guild's prefix.
+func (r *Router) HelpEmbed(guildID string) *discordgo.MessageEmbed {
+	prefix := r.prefix(guildID)
+	var help strings.Builder
+	for _, cmd := range r.Commands() {
+		usage := cmd.Name
+		if cmd.Usage != "" {
+			usage += " " + cmd.Usage
+		}
+		help.WriteString(fmt.Sprintf("`%s%s`", prefix, usage))
+		if cmd.Description != "" {
+			help.WriteString(" — " + cmd.Description)
+		}
+		help.WriteString("\n")
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "Commands",
+		Description: help.String(),
+		Color:       0x3498db,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Prefix: %s — you can also mention the bot", prefix),
+		},
+	}
+}
@@ -0,0 +1,46 @@
+// Package schema holds helpers for migrating the SQLite schema of tables
+// that predate newer columns.
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Columns returns the names of a table's columns.
+func Columns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// AddColumnIfMissing adds a column to a table unless it already exists.
+func AddColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	columns, err := Columns(db, table)
+	if err != nil {
+		return fmt.Errorf("reading columns of %s: %w", table, err)
+	}
+	if columns[column] {
+		return nil
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("adding column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Package settings stores per-guild bot settings such as the command prefix.
+package settings
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"aware/schema"
+)
+
+// DefaultPrefix is used by guilds that haven't configured their own.
+const DefaultPrefix = ","
+
+// MaxPrefixLength bounds custom prefixes.
+const MaxPrefixLength = 5
+
+// DefaultRetentionDays is how long stored messages are kept by default.
+const DefaultRetentionDays = 7
+
+// MaxRetentionDays bounds the message retention period.
+const MaxRetentionDays = 90
+
+// Guild holds a guild's settings.
+type Guild struct {
+	Prefix string
+	// StoreMessages opts the guild in to storing message content, which
+	// edit and delete logs rely on.
+	StoreMessages bool
+	RetentionDays int
+}
+
+var defaults = Guild{Prefix: DefaultPrefix, RetentionDays: DefaultRetentionDays}
+
+var (
+	db *sql.DB
+
+	cache      = make(map[string]Guild)
+	cacheMutex sync.RWMutex
+)
+
+// Init stores the database handle and creates the settings table.
+func Init(database *sql.DB) {
+	db = database
+
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS guild_settings (
+            guild_id TEXT PRIMARY KEY,
+            prefix TEXT,
+            store_messages BOOLEAN DEFAULT false,
+            message_retention_days INTEGER
+        )
+    `)
+	if err != nil {
+		fmt.Printf("Error creating guild_settings table: %v\n", err)
+	}
+
+	for _, column := range [][2]string{
+		{"store_messages", "BOOLEAN DEFAULT false"},
+		{"message_retention_days", "INTEGER"},
+	} {
+		if err := schema.AddColumnIfMissing(db, "guild_settings", column[0], column[1]); err != nil {
+			fmt.Printf("Error migrating guild_settings: %v\n", err)
+		}
+	}
+}
+
+// Get returns a guild's settings, filling in defaults for anything unset.
+func Get(guildID string) Guild {
+	if guildID == "" {
+		return defaults
+	}
+
+	cacheMutex.RLock()
+	guild, ok := cache[guildID]
+	cacheMutex.RUnlock()
+	if ok {
+		return guild
+	}
+
+	var prefix sql.NullString
+	var storeMessages sql.NullBool
+	var retention sql.NullInt64
+	err := db.QueryRow(`
+        SELECT prefix, store_messages, message_retention_days
+        FROM guild_settings WHERE guild_id = ?`, guildID).Scan(&prefix, &storeMessages, &retention)
+	if err != nil && err != sql.ErrNoRows {
+		// Don't cache lookup failures
+		fmt.Printf("Error loading settings for guild %s: %v\n", guildID, err)
+		return defaults
+	}
+
+	guild = defaults
+	if prefix.String != "" {
+		guild.Prefix = prefix.String
+	}
+	guild.StoreMessages = storeMessages.Bool
+	if retention.Valid && retention.Int64 > 0 {
+		guild.RetentionDays = int(retention.Int64)
+	}
+
+	cacheMutex.Lock()
+	cache[guildID] = guild
+	cacheMutex.Unlock()
+	return guild
+}
+
+// Prefix returns the command prefix for a guild.
+func Prefix(guildID string) string {
+	return Get(guildID).Prefix
+}
+
+// ValidatePrefix checks a prefix before it is stored.
+func ValidatePrefix(prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("Prefix can't be empty")
+	}
+	if len(prefix) > MaxPrefixLength {
+		return fmt.Errorf("Prefix must be at most %d characters", MaxPrefixLength)
+	}
+	if strings.ContainsAny(prefix, " \t\n`") {
+		return fmt.Errorf("Prefix can't contain spaces or backticks")
+	}
+	return nil
+}
+
+// set upserts a single settings column and drops the guild from the cache.
+func set(guildID, column string, value interface{}) error {
+	_, err := db.Exec(fmt.Sprintf(`
+        INSERT INTO guild_settings (guild_id, %[1]s) VALUES (?, ?)
+        ON CONFLICT(guild_id) DO UPDATE SET %[1]s = excluded.%[1]s`, column),
+		guildID, value,
+	)
+	if err != nil {
+		return err
+	}
+
+	cacheMutex.Lock()
+	delete(cache, guildID)
+	cacheMutex.Unlock()
+	return nil
+}
+
+// SetPrefix stores a guild's prefix. An empty prefix resets it to the default.
+func SetPrefix(guildID, prefix string) error {
+	if prefix != "" {
+		if err := ValidatePrefix(prefix); err != nil {
+			return err
+		}
+	}
+	return set(guildID, "prefix", prefix)
+}
+
+// SetStoreMessages opts a guild in to or out of message storage.
+func SetStoreMessages(guildID string, enabled bool) error {
+	return set(guildID, "store_messages", enabled)
+}
+
+// ValidateRetention checks a retention period in days.
+func ValidateRetention(days int) error {
+	if days < 1 || days > MaxRetentionDays {
+		return fmt.Errorf("Retention must be between 1 and %d days", MaxRetentionDays)
+	}
+	return nil
+}
+
+// SetRetentionDays stores how long a guild's messages are kept.
+func SetRetentionDays(guildID string, days int) error {
+	if err := ValidateRetention(days); err != nil {
+		return err
+	}
+	return set(guildID, "message_retention_days", days)
+}